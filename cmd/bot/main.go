@@ -9,6 +9,7 @@ import (
 
 	"github.com/okpulse/telegram-schedule-bot/internal/bot"
 	"github.com/okpulse/telegram-schedule-bot/internal/config"
+	"github.com/okpulse/telegram-schedule-bot/internal/notify"
 	"github.com/okpulse/telegram-schedule-bot/internal/scheduler"
 	"github.com/okpulse/telegram-schedule-bot/internal/store"
 )
@@ -22,6 +23,14 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if _, err := st.DeleteExpiredConversations(st.Clock.Now()); err != nil {
+		log.Println("purge conversations:", err)
+	}
+	// the delivery log is kept for a month
+	if _, err := st.PurgeOutbox(st.Clock.Now().AddDate(0, 0, -30)); err != nil {
+		log.Println("purge outbox:", err)
+	}
+
 	pref := telebot.Settings{
 		Token:  cfg.BotToken,
 		Poller: &telebot.LongPoller{Timeout: 10 * time.Second},
@@ -31,13 +40,41 @@ func main() {
 		log.Fatal(err)
 	}
 
-	sch := scheduler.New(b, st)
+	router := &notify.Router{
+		Telegram: notify.Telegram{Bot: b},
+		St:       st,
+		SMTP:     notify.SMTP{Addr: cfg.SMTPAddr, From: cfg.SMTPFrom},
+	}
+	if cfg.NotifyLog != "" {
+		if router.Log, err = notify.OpenLog(cfg.NotifyLog); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	outbox := notify.NewOutbox(router)
+	sch := scheduler.New(outbox, st)
+	outbox.OnBlocked = func(userID int64) {
+		log.Printf("user %d blocked the bot, control disabled", userID)
+		if err := st.SetControl(userID, false); err != nil {
+			log.Println("disable control:", err)
+		}
+		sch.ClearUser(userID)
+	}
+	sch.NotifyMissed = cfg.NotifyMissed
+	sch.TimerMax = cfg.TimerMax
 	app := bot.New(b, st, sch)
+	app.AdminIDs = cfg.AdminIDs
+	app.Router = router
 	app.SetupHandlers(cfg.DefaultTZ)
 
+	outbox.Start()
+	sch.ArchivePast()
 	if err := sch.RescheduleEnabledUsers(); err != nil {
 		log.Println("reschedule:", err)
 	}
+	if err := sch.RestoreTimers(); err != nil {
+		log.Println("restore timers:", err)
+	}
 
 	b.Start()
 }
@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+func (a *BotApp) isAdmin(tgID int64) bool {
+	for _, id := range a.AdminIDs {
+		if id == tgID {
+			return true
+		}
+	}
+	return false
+}
+
+// handleMigrate — /migrate [status|up|down]: управление миграциями схемы (только для админов)
+func (a *BotApp) handleMigrate(c telebot.Context) error {
+	if !a.isAdmin(c.Sender().ID) {
+		return nil
+	}
+	args := strings.Fields(c.Message().Payload)
+	cmd := "status"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "status":
+		list, err := a.St.MigrationStatus()
+		if err != nil {
+			return c.Send("Ошибка: " + err.Error())
+		}
+		var b strings.Builder
+		b.WriteString("Миграции:\n")
+		for _, m := range list {
+			if m.Applied {
+				fmt.Fprintf(&b, "✅ %03d_%s — %s\n", m.Version, m.Name, m.AppliedAt.UTC().Format("2006-01-02 15:04 UTC"))
+			} else {
+				fmt.Fprintf(&b, "⏳ %03d_%s — не применена\n", m.Version, m.Name)
+			}
+		}
+		return c.Send(b.String())
+	case "up":
+		n, err := a.St.MigrateUp()
+		if err != nil {
+			log.Println("migrate up:", err)
+			return c.Send(fmt.Sprintf("Применено %d, ошибка: %v", n, err))
+		}
+		return c.Send(fmt.Sprintf("Применено миграций: %d", n))
+	case "down":
+		m, err := a.St.RollbackMigration()
+		if err != nil {
+			log.Println("migrate down:", err)
+			return c.Send("Откат не выполнен: " + err.Error())
+		}
+		log.Printf("migration %03d_%s rolled back by %d", m.Version, m.Name, c.Sender().ID)
+		return c.Send(fmt.Sprintf("Откат выполнен: %03d_%s. Перезапустите бота или выполните /migrate up.", m.Version, m.Name))
+	}
+	return c.Send("Использование: /migrate [status|up|down]")
+}
+
+// priorityNames names the notify.Event priorities for /outbox.
+var priorityNames = map[int]string{0: "старт/финиш", 1: "напоминания", 2: "прочее", 3: "сводки"}
+
+// handleOutbox — /outbox: журнал доставки уведомлений за сутки, очередь, задержки и последние ошибки (только для админов)
+func (a *BotApp) handleOutbox(c telebot.Context) error {
+	if !a.isAdmin(c.Sender().ID) {
+		return nil
+	}
+	now := a.St.Clock.Now()
+	counts, err := a.St.OutboxStats(now.Add(-24 * time.Hour))
+	if err != nil {
+		return c.Send("Ошибка: " + err.Error())
+	}
+	var b strings.Builder
+	b.WriteString("Уведомления за сутки:\n")
+	if len(counts) == 0 {
+		b.WriteString("нет\n")
+	}
+	for _, n := range counts {
+		fmt.Fprintf(&b, "%s — %s: %d\n", n.Channel, n.Status, n.N)
+	}
+	depth, err := a.St.OutboxQueue(now)
+	if err != nil {
+		return c.Send("Ошибка: " + err.Error())
+	}
+	fmt.Fprintf(&b, "\nВ очереди: %d, из них пора отправить: %d", depth.Pending, depth.Due)
+	if depth.OldestDue != nil {
+		fmt.Fprintf(&b, " (самое старое ждёт %s)", now.Sub(time.Unix(*depth.OldestDue, 0)).Round(time.Second))
+	}
+	b.WriteString("\n")
+	delays, err := a.St.OutboxDelays(now.Add(-time.Hour))
+	if err != nil {
+		return c.Send("Ошибка: " + err.Error())
+	}
+	if len(delays) > 0 {
+		b.WriteString("\nЗадержка доставки за час (среднее / максимум):\n")
+	}
+	for _, d := range delays {
+		fmt.Fprintf(&b, "%s: %d шт., %.0f с / %d с\n", priorityNames[d.Priority], d.N, d.Avg, d.Max)
+	}
+	failed, err := a.St.FailedOutbox(5)
+	if err != nil {
+		return c.Send("Ошибка: " + err.Error())
+	}
+	if len(failed) > 0 {
+		b.WriteString("\nПоследние ошибки:\n")
+	}
+	for _, m := range failed {
+		reason := ""
+		if m.LastError != nil {
+			reason = *m.LastError
+		}
+		fmt.Fprintf(&b, "#%d %s, user %d, %s, попыток %d: %s\n", m.ID, m.Channel, m.UserID,
+			time.Unix(m.CreatedAt, 0).UTC().Format("2006-01-02 15:04 UTC"), m.Attempts, reason)
+	}
+	return c.Send(b.String())
+}
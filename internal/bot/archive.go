@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+)
+
+func (a *BotApp) buildArchivedTaskText(t store.Task, tz string) string {
+	text := fmt.Sprintf("🗄 %02d:%02d–%02d:%02d %s\n%s", t.StartH, t.StartM, t.EndH, t.EndM, t.Title, a.taskDays(t))
+	if t.ArchivedAt != nil {
+		when := time.Unix(*t.ArchivedAt, 0)
+		if loc, err := time.LoadLocation(tz); err == nil {
+			when = when.In(loc)
+		}
+		text += "\nУдалена: " + when.Format("02.01.2006 15:04")
+	}
+	return text
+}
+
+func (a *BotApp) buildArchivedTaskMarkup(t store.Task) *telebot.ReplyMarkup {
+	mk := &telebot.ReplyMarkup{}
+	bR := mk.Data("Восстановить", a.btnArchiveRestore.Unique, fmt.Sprintf("%d", t.ID))
+	bP := mk.Data("Удалить навсегда", a.btnArchivePurge.Unique, fmt.Sprintf("%d", t.ID))
+	mk.Inline(mk.Row(bR, bP))
+	return mk
+}
+
+func (a *BotApp) handleArchive(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Сначала /start")
+	}
+	tasks, err := a.St.ListArchivedTasks(u.ID)
+	if err != nil {
+		return c.Send("Ошибка чтения архива")
+	}
+	if len(tasks) == 0 {
+		return c.Send("Архив пуст.")
+	}
+	for _, t := range tasks {
+		_ = c.Send(a.buildArchivedTaskText(t, u.TZ), a.buildArchivedTaskMarkup(t))
+	}
+	return nil
+}
+
+func (a *BotApp) cbArchiveOpen(c telebot.Context) error {
+	_ = c.Respond()
+	return a.handleArchive(c)
+}
+
+func (a *BotApp) cbArchiveRestore(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	var taskID int64
+	fmt.Sscanf(c.Callback().Data, "%d", &taskID)
+	if err := a.St.RestoreTask(u.ID, taskID, a.userNow(u)); errors.Is(err, store.ErrTaskOver) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Дата задачи уже прошла — добавьте её заново с новой датой", ShowAlert: true})
+	} else if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	if u.ControlEnabled {
+		_ = a.Sch.ScheduleAllForUser(u)
+	}
+	t, err := a.St.GetTask(u.ID, taskID)
+	if err == nil {
+		_ = c.Edit(a.buildTaskText(t), a.buildTaskMarkup(t))
+	}
+	return c.Respond(&telebot.CallbackResponse{Text: "Задача восстановлена"})
+}
+
+// cbArchivePurge — просит подтвердить безвозвратное удаление вместе с историей
+func (a *BotApp) cbArchivePurge(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	var taskID int64
+	fmt.Sscanf(c.Callback().Data, "%d", &taskID)
+	t, err := a.St.GetTask(u.ID, taskID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Задача не найдена"})
+	}
+	mk := &telebot.ReplyMarkup{}
+	bOK := mk.Data("Да, удалить с историей", a.btnArchivePurgeOK.Unique, fmt.Sprintf("%d", t.ID))
+	bNo := mk.Data("Отмена", a.btnArchiveKeep.Unique, fmt.Sprintf("%d", t.ID))
+	mk.Inline(mk.Row(bOK, bNo))
+	_ = c.Respond()
+	return c.Edit(a.buildArchivedTaskText(t, u.TZ)+"\n\n⚠️ Учтённое время этой задачи пропадёт из отчётов.", mk)
+}
+
+func (a *BotApp) cbArchiveKeep(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	var taskID int64
+	fmt.Sscanf(c.Callback().Data, "%d", &taskID)
+	if t, err := a.St.GetTask(u.ID, taskID); err == nil {
+		_ = c.Edit(a.buildArchivedTaskText(t, u.TZ), a.buildArchivedTaskMarkup(t))
+	}
+	return c.Respond()
+}
+
+func (a *BotApp) cbArchivePurgeOK(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	var taskID int64
+	fmt.Sscanf(c.Callback().Data, "%d", &taskID)
+	if err := a.St.PurgeTask(u.ID, taskID); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	_ = c.Delete()
+	return c.Respond(&telebot.CallbackResponse{Text: "Удалено навсегда"})
+}
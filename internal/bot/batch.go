@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+)
+
+// stateBatchConfirm waits for the user to confirm a batch of new tasks
+// (calendar import, quick entry).
+const stateBatchConfirm = "batch:confirm"
+
+// maxBatchTasks caps how many tasks one import may create.
+const maxBatchTasks = 50
+
+// BatchDraft holds tasks awaiting confirmation.
+type BatchDraft struct {
+	Tasks []AddState `json:"tasks"`
+}
+
+// sameTask reports whether the draft duplicates an existing task.
+func (st AddState) sameTask(o AddState) bool {
+	return strings.EqualFold(st.Title, o.Title) && st.StartH == o.StartH && st.StartM == o.StartM &&
+		st.EndH == o.EndH && st.EndM == o.EndM && st.DaysMask == o.DaysMask && st.Date == o.Date && st.RRule == o.RRule
+}
+
+// dropExisting removes drafts that duplicate the user's tasks or each
+// other; the returned notes name what was dropped.
+func (a *BotApp) dropExisting(userID int64, drafts []AddState) ([]AddState, []string) {
+	tasks, _ := a.St.ListTasks(userID)
+	var (
+		out   []AddState
+		notes []string
+	)
+next:
+	for _, d := range drafts {
+		for _, t := range tasks {
+			if d.sameTask(draftFromTask(t)) {
+				notes = append(notes, fmt.Sprintf("%s — уже есть в списке", d.Title))
+				continue next
+			}
+		}
+		for _, o := range out {
+			if d.sameTask(o) {
+				continue next
+			}
+		}
+		out = append(out, d)
+	}
+	return out, notes
+}
+
+// sendBatchPreview lists the drafts and the problems found and, if there is
+// anything to add, asks for confirmation.
+func (a *BotApp) sendBatchPreview(c telebot.Context, drafts []AddState, problems []string) error {
+	var b strings.Builder
+	if len(drafts) > maxBatchTasks {
+		problems = append(problems, fmt.Sprintf("ещё %d задач — не больше %d за раз", len(drafts)-maxBatchTasks, maxBatchTasks))
+		drafts = drafts[:maxBatchTasks]
+	}
+	if len(drafts) > 0 {
+		fmt.Fprintf(&b, "Будут добавлены задачи (%d):\n", len(drafts))
+		for _, d := range drafts {
+			b.WriteString(a.buildTaskText(d.task(0)))
+			if len(d.Skips) > 0 {
+				fmt.Fprintf(&b, " (кроме %d дат)", len(d.Skips))
+			}
+			b.WriteString("\n")
+		}
+	} else {
+		b.WriteString("Нечего добавлять.\n")
+	}
+	if len(problems) > 0 {
+		b.WriteString("\nНе добавлены:\n")
+		for _, p := range problems {
+			line := "• " + p + "\n"
+			if b.Len()+len(line) > maxReportLen {
+				b.WriteString("…\n")
+				break
+			}
+			b.WriteString(line)
+		}
+	}
+	if len(drafts) == 0 {
+		return c.Send(b.String())
+	}
+	if err := a.batchFlow.Start(c.Sender().ID, stateBatchConfirm, BatchDraft{Tasks: drafts}); err != nil {
+		return c.Send("Ошибка")
+	}
+	mk := &telebot.ReplyMarkup{}
+	mk.Inline(mk.Row(
+		mk.Data(fmt.Sprintf("✅ Добавить (%d)", len(drafts)), a.btnBatchOK.Unique, "ok"),
+		mk.Data("✖️ Отмена", a.btnBatchCancel.Unique, "cancel"),
+	))
+	return c.Send(strings.TrimRight(b.String(), "\n"), mk)
+}
+
+func (a *BotApp) cbBatchOK(c telebot.Context) error {
+	id := c.Sender().ID
+	s, err := a.batchFlow.Get(id)
+	if err != nil || s == nil || s.State != stateBatchConfirm {
+		return c.Respond(&telebot.CallbackResponse{Text: "Нечего добавлять"})
+	}
+	u, err := a.St.GetUserByTGID(id)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	if err := a.batchFlow.Reset(id); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	added := 0
+	for _, d := range s.Data.Tasks {
+		taskID, err := a.St.CreateTask(d.task(u.ID))
+		if err != nil {
+			continue
+		}
+		added++
+		for _, day := range d.Skips {
+			_, _ = a.St.AddHoliday(store.Holiday{UserID: u.ID, TaskID: &taskID, Day: day})
+		}
+	}
+	if u.ControlEnabled {
+		_ = a.Sch.ScheduleAllForUser(u)
+	}
+	_ = c.Respond()
+	text := fmt.Sprintf("✅ Добавлено задач: %d. Список — /list", added)
+	if added < len(s.Data.Tasks) {
+		text += fmt.Sprintf("\nНе удалось сохранить: %d", len(s.Data.Tasks)-added)
+	}
+	if !u.ControlEnabled {
+		text += "\nЧтобы получать уведомления, запустите контроль: /run"
+	}
+	return c.Edit(text)
+}
+
+func (a *BotApp) cbBatchCancel(c telebot.Context) error {
+	s, err := a.batchFlow.Get(c.Sender().ID)
+	if err == nil && s != nil && s.State == stateBatchConfirm {
+		_ = a.batchFlow.Reset(c.Sender().ID)
+	}
+	_ = c.Respond()
+	return c.Edit("Отменено.")
+}
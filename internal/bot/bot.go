@@ -3,16 +3,18 @@ package bot
 import (
 	"fmt"
 	"log"
-	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"gopkg.in/telebot.v3"
 
+	"github.com/okpulse/telegram-schedule-bot/internal/fsm"
+	"github.com/okpulse/telegram-schedule-bot/internal/notify"
 	"github.com/okpulse/telegram-schedule-bot/internal/scheduler"
 	"github.com/okpulse/telegram-schedule-bot/internal/store"
 	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+	"github.com/okpulse/telegram-schedule-bot/internal/when"
 )
 
 const chooseDaysTitle = "Выберите дни (нажимайте, затем 'Готово')"
@@ -22,8 +24,23 @@ type BotApp struct {
 	St  *store.Store
 	Sch *scheduler.Scheduler
 
-	addMu    sync.Mutex
-	addState map[int64]*AddState
+	// AdminIDs are Telegram user IDs allowed to use admin commands.
+	AdminIDs []int64
+	// Router knows which extra notification channels (/notify) the server
+	// supports; nil means Telegram only.
+	Router *notify.Router
+	// when each user was last mailed a /notify confirmation code; kept in
+	// memory too, so removing the unconfirmed address does not lift the limit
+	codeMu   sync.Mutex
+	codeSent map[int64]time.Time
+
+	// per-user dialog state (add wizard, edit flow), persisted in SQLite
+	flow *fsm.Machine[telebot.Context, AddState]
+	// actual finish time entry after "Закончил раньше"; shares the
+	// conversation slot with flow
+	finishFlow *fsm.Machine[telebot.Context, FinishDraft]
+	// tasks from an import awaiting confirmation; same slot again
+	batchFlow *fsm.Machine[telebot.Context, BatchDraft]
 
 	// repeat menu
 	repMK       *telebot.ReplyMarkup
@@ -31,6 +48,21 @@ type BotApp struct {
 	btnRepDaily telebot.Btn
 	btnRepWork  telebot.Btn
 	btnRepCust  telebot.Btn
+	btnRepDate  telebot.Btn
+	btnRepRule  telebot.Btn
+
+	// recurrence rule presets and their end
+	btnRulePreset telebot.Btn
+	btnRuleLimit  telebot.Btn
+
+	// date picker: day, month arrows and inert cells
+	btnCalDay  telebot.Btn
+	btnCalNav  telebot.Btn
+	btnCalNoop telebot.Btn
+
+	// holiday and skip date calendars
+	btnHolDay telebot.Btn
+	btnHolNav telebot.Btn
 
 	// custom days (use static uniques; keyboard re-rendered with labels)
 	btnMon      telebot.Btn
@@ -48,29 +80,131 @@ type BotApp struct {
 	btnRptWeek  telebot.Btn
 	btnRptMonth telebot.Btn
 	btnRptAll   telebot.Btn
+	// prev/next and per-day toggle under a report; data encodes the range
+	btnRptNav telebot.Btn
 
 	// per-task list buttons
 	btnTaskToggle telebot.Btn
 	btnTaskDelete telebot.Btn
+	btnTaskEdit   telebot.Btn
+	btnEditField  telebot.Btn
+
+	// reminder choice buttons
+	btnRemindSet  telebot.Btn
+	btnRemindDone telebot.Btn
+
+	// manual timer buttons
+	btnTimerTask telebot.Btn
+	btnTimerStop telebot.Btn
+
+	// export buttons
+	btnExportFormat telebot.Btn
+	btnExportGet    telebot.Btn
+	btnICS          telebot.Btn
+
+	// batch (import) confirmation buttons
+	btnBatchOK     telebot.Btn
+	btnBatchCancel telebot.Btn
+
+	// archive buttons
+	btnArchiveOpen    telebot.Btn
+	btnArchiveRestore telebot.Btn
+	btnArchivePurge   telebot.Btn
+	btnArchivePurgeOK telebot.Btn
+	btnArchiveKeep    telebot.Btn
 
 	// post-add confirmation buttons
 	btnAddAnother   telebot.Btn
 	btnStartControl telebot.Btn
 }
 
+// conversationTTL is how long an unfinished add/edit flow is kept.
+const conversationTTL = 24 * time.Hour
+
+// flow states
+const (
+	stateAddTitle   = "add:title"
+	stateAddStart   = "add:start"
+	stateAddEnd     = "add:end"
+	stateAddRepeat  = "add:repeat"
+	stateAddDays    = "add:days"
+	stateAddDate    = "add:date"
+	stateAddRemind  = "add:remind"
+	stateEditTitle  = "edit:title"
+	stateEditStart  = "edit:start"
+	stateEditEnd    = "edit:end"
+	stateEditDays   = "edit:days"
+	stateEditDate   = "edit:date"
+	stateEditRemind = "edit:remind"
+)
+
+// AddState is the task draft carried through the add wizard and edit flow.
 type AddState struct {
-	Step     int
-	Title    string
-	StartH   int
-	StartM   int
-	EndH     int
-	EndM     int
-	Repeat   string
-	DaysMask int
+	EditID   int64  `json:"edit_id,omitempty"` // task being edited; 0 for the add wizard
+	Title    string `json:"title"`
+	StartH   int    `json:"start_h"`
+	StartM   int    `json:"start_m"`
+	EndH     int    `json:"end_h"`
+	EndM     int    `json:"end_m"`
+	DaysMask int    `json:"days_mask"`
+	// Date (YYYY-MM-DD) makes a one-off task; empty for repeating ones
+	Date string `json:"date,omitempty"`
+	// RRule repeats the task by a recurrence rule counted from RRuleStart
+	// (YYYY-MM-DD); DaysMask then holds the weekdays it can fall on
+	RRule      string `json:"rrule,omitempty"`
+	RRuleStart string `json:"rrule_start,omitempty"`
+	// Skips are dates (YYYY-MM-DD) the new task does not run on, taken from
+	// the EXDATEs of an imported event
+	Skips []string `json:"skips,omitempty"`
+	// reminder lead times in minutes; nil = user default
+	RemindStart *int `json:"remind_start,omitempty"`
+	RemindEnd   *int `json:"remind_end,omitempty"`
+}
+
+func draftFromTask(t store.Task) AddState {
+	st := AddState{
+		EditID: t.ID, Title: t.Title,
+		StartH: t.StartH, StartM: t.StartM,
+		EndH: t.EndH, EndM: t.EndM,
+		DaysMask:    t.DaysMask,
+		RemindStart: t.RemindStart, RemindEnd: t.RemindEnd,
+	}
+	if t.OnDate != nil {
+		st.Date = *t.OnDate
+	}
+	if t.RRule != nil && t.RRuleStart != nil {
+		st.RRule, st.RRuleStart = *t.RRule, *t.RRuleStart
+	}
+	return st
+}
+
+func (st AddState) task(userID int64) store.Task {
+	t := store.Task{
+		ID: st.EditID, UserID: userID, Title: st.Title,
+		StartH: st.StartH, StartM: st.StartM,
+		EndH: st.EndH, EndM: st.EndM,
+		DaysMask:    st.DaysMask,
+		RemindStart: st.RemindStart, RemindEnd: st.RemindEnd,
+	}
+	if st.Date != "" {
+		date := st.Date
+		t.OnDate = &date
+	}
+	if st.RRule != "" {
+		rule, start := st.RRule, st.RRuleStart
+		t.RRule, t.RRuleStart = &rule, &start
+	}
+	return t
 }
 
 func New(b *telebot.Bot, st *store.Store, sch *scheduler.Scheduler) *BotApp {
-	return &BotApp{Bot: b, St: st, Sch: sch, addState: make(map[int64]*AddState)}
+	return &BotApp{
+		Bot: b, St: st, Sch: sch,
+		flow:       fsm.New[telebot.Context, AddState](st, conversationTTL),
+		finishFlow: fsm.New[telebot.Context, FinishDraft](st, conversationTTL),
+		batchFlow:  fsm.New[telebot.Context, BatchDraft](st, conversationTTL),
+		codeSent:   map[int64]time.Time{},
+	}
 }
 
 func (a *BotApp) SetupHandlers(defaultTZ string) {
@@ -82,7 +216,8 @@ func (a *BotApp) SetupHandlers(defaultTZ string) {
 	btnStop := rp.Text("⏹ Остановить")
 	btnTZ := rp.Text("🕒 Тайм-зона")
 	btnReport := rp.Text("📊 Отчёт")
-	rp.Reply(rp.Row(btnAdd, btnList), rp.Row(btnRun, btnStop), rp.Row(btnTZ, btnReport))
+	btnTimer := rp.Text("⏱ Таймер")
+	rp.Reply(rp.Row(btnAdd, btnList), rp.Row(btnRun, btnStop), rp.Row(btnTZ, btnReport), rp.Row(btnTimer))
 
 	// repeat menu
 	a.repMK = &telebot.ReplyMarkup{}
@@ -90,7 +225,19 @@ func (a *BotApp) SetupHandlers(defaultTZ string) {
 	a.btnRepDaily = a.repMK.Data("Ежедневно", "rep_daily", "daily")
 	a.btnRepWork = a.repMK.Data("Рабочие дни", "rep_workdays", "workdays")
 	a.btnRepCust = a.repMK.Data("Выбрать дни", "rep_custom", "custom")
-	a.repMK.Inline(a.repMK.Row(a.btnRepToday, a.btnRepDaily), a.repMK.Row(a.btnRepWork, a.btnRepCust))
+	a.btnRepDate = a.repMK.Data("📅 Дата", "rep_date", "date")
+	a.btnRepRule = a.repMK.Data("🔁 Другой повтор", "rep_rule", "rule")
+	a.repMK.Inline(a.repMK.Row(a.btnRepToday, a.btnRepDate), a.repMK.Row(a.btnRepDaily, a.btnRepWork), a.repMK.Row(a.btnRepCust, a.btnRepRule))
+
+	a.btnRulePreset = telebot.Btn{Unique: "rule_preset"}
+	a.btnRuleLimit = telebot.Btn{Unique: "rule_limit"}
+
+	// date picker
+	a.btnCalDay = telebot.Btn{Unique: "cal_day"}
+	a.btnCalNav = telebot.Btn{Unique: "cal_nav"}
+	a.btnCalNoop = telebot.Btn{Unique: "cal_noop"}
+	a.btnHolDay = telebot.Btn{Unique: "hol_day"}
+	a.btnHolNav = telebot.Btn{Unique: "hol_nav"}
 
 	// custom day uniques
 	a.btnMon = telebot.Btn{Unique: "day_mon"}
@@ -109,10 +256,37 @@ func (a *BotApp) SetupHandlers(defaultTZ string) {
 	a.btnRptMonth = a.reportMK.Data("Месяц", "rpt_month", "month")
 	a.btnRptAll = a.reportMK.Data("Всё время", "rpt_all", "all")
 	a.reportMK.Inline(a.reportMK.Row(a.btnRptDay, a.btnRptWeek), a.reportMK.Row(a.btnRptMonth, a.btnRptAll))
+	a.btnRptNav = telebot.Btn{Unique: "rpt_nav"}
 
 	// per-task buttons
 	a.btnTaskToggle = telebot.Btn{Unique: "task_toggle"}
 	a.btnTaskDelete = telebot.Btn{Unique: "task_delete"}
+	a.btnTaskEdit = telebot.Btn{Unique: "task_edit"}
+	a.btnEditField = telebot.Btn{Unique: "edit_field"}
+
+	// reminder choice buttons
+	a.btnRemindSet = telebot.Btn{Unique: "remind_set"}
+	a.btnRemindDone = telebot.Btn{Unique: "remind_done"}
+
+	// manual timer buttons
+	a.btnTimerTask = telebot.Btn{Unique: "timer_task"}
+	a.btnTimerStop = telebot.Btn{Unique: "timer_stop"}
+
+	// export buttons
+	a.btnExportFormat = telebot.Btn{Unique: "export_fmt"}
+	a.btnExportGet = telebot.Btn{Unique: "export_get"}
+	a.btnICS = telebot.Btn{Unique: "ics_get"}
+
+	// batch confirmation buttons
+	a.btnBatchOK = telebot.Btn{Unique: "batch_ok"}
+	a.btnBatchCancel = telebot.Btn{Unique: "batch_cancel"}
+
+	// archive buttons
+	a.btnArchiveOpen = telebot.Btn{Unique: "archive_open"}
+	a.btnArchiveRestore = telebot.Btn{Unique: "archive_restore"}
+	a.btnArchivePurge = telebot.Btn{Unique: "archive_purge"}
+	a.btnArchivePurgeOK = telebot.Btn{Unique: "archive_purge_ok"}
+	a.btnArchiveKeep = telebot.Btn{Unique: "archive_keep"}
 
 	// post-add confirmation buttons
 	a.btnAddAnother = telebot.Btn{Unique: "add_another"}
@@ -124,7 +298,7 @@ func (a *BotApp) SetupHandlers(defaultTZ string) {
 		return c.Send("Привет! Я помогу контролировать расписание. Используй кнопки ниже или команды /add /list /run /stop /report /help.", rp)
 	})
 	a.Bot.Handle("/help", func(c telebot.Context) error {
-		return c.Send("Команды:\n/add — добавить задачу\n/list — список задач\n/run — запустить контроль\n/stop — остановить контроль\n/tz — сменить тайм-зону\n/report — отчёт по времени")
+		return c.Send("Команды:\n/add — добавить задачу\n/list — список задач\n/archive — архив удалённых задач\n/run — запустить контроль\n/stop — остановить контроль\n/cancel — прервать добавление или редактирование\n/tz — сменить тайм-зону\n/remind — напоминания по умолчанию\n/confirm — учёт старта без ответа\n/holiday — выходные дни без задач\n/notify — уведомления на почту, webhook или в журнал\n/report — отчёт по времени\n/timer — секундомер для внеплановой работы\n/export — выгрузка в CSV или JSON\n/ics — расписание для календаря\n\nЧтобы перенести задачи из календаря, пришлите файл .ics")
 	})
 	a.Bot.Handle(&btnAdd, func(c telebot.Context) error { return a.handleAddStart(c, defaultTZ) })
 	a.Bot.Handle("/add", func(c telebot.Context) error { return a.handleAddStart(c, defaultTZ) })
@@ -138,6 +312,18 @@ func (a *BotApp) SetupHandlers(defaultTZ string) {
 	a.Bot.Handle("/tz", a.handleTZ)
 	a.Bot.Handle(&btnReport, a.handleReportMenu)
 	a.Bot.Handle("/report", a.handleReportMenu)
+	a.Bot.Handle(&btnTimer, a.handleTimer)
+	a.Bot.Handle("/timer", a.handleTimer)
+	a.Bot.Handle("/archive", a.handleArchive)
+	a.Bot.Handle("/export", a.handleExport)
+	a.Bot.Handle("/ics", a.handleICS)
+	a.Bot.Handle("/remind", a.handleRemind)
+	a.Bot.Handle("/confirm", a.handleConfirm)
+	a.Bot.Handle("/holiday", a.handleHoliday)
+	a.Bot.Handle("/notify", a.handleNotify)
+	a.Bot.Handle("/cancel", a.handleCancel)
+	a.Bot.Handle("/migrate", a.handleMigrate)
+	a.Bot.Handle("/outbox", a.handleOutbox)
 
 	// inline handlers
 	// repeat
@@ -145,6 +331,16 @@ func (a *BotApp) SetupHandlers(defaultTZ string) {
 	a.Bot.Handle(&a.btnRepDaily, func(c telebot.Context) error { return a.cbRepeatChoice(c, "daily") })
 	a.Bot.Handle(&a.btnRepWork, func(c telebot.Context) error { return a.cbRepeatChoice(c, "workdays") })
 	a.Bot.Handle(&a.btnRepCust, func(c telebot.Context) error { return a.cbRepeatChoice(c, "custom") })
+	a.Bot.Handle(&a.btnRepDate, func(c telebot.Context) error { return a.cbRepeatChoice(c, "date") })
+	a.Bot.Handle(&a.btnRepRule, func(c telebot.Context) error { return a.cbRepeatChoice(c, "rule") })
+	a.Bot.Handle(&a.btnRulePreset, a.cbRulePreset)
+	a.Bot.Handle(&a.btnRuleLimit, a.cbRuleLimit)
+	// date picker
+	a.Bot.Handle(&a.btnCalDay, a.cbCalendarDay)
+	a.Bot.Handle(&a.btnCalNav, a.cbCalendarNav)
+	a.Bot.Handle(&a.btnCalNoop, func(c telebot.Context) error { return c.Respond() })
+	a.Bot.Handle(&a.btnHolDay, a.cbHolidayDay)
+	a.Bot.Handle(&a.btnHolNav, a.cbHolidayNav)
 	// custom days
 	a.Bot.Handle(&a.btnMon, func(c telebot.Context) error { return a.cbToggleDay(c, time.Monday) })
 	a.Bot.Handle(&a.btnTue, func(c telebot.Context) error { return a.cbToggleDay(c, time.Tuesday) })
@@ -155,27 +351,83 @@ func (a *BotApp) SetupHandlers(defaultTZ string) {
 	a.Bot.Handle(&a.btnSun, func(c telebot.Context) error { return a.cbToggleDay(c, time.Sunday) })
 	a.Bot.Handle(&a.btnDaysDone, a.cbDaysDone)
 	// reports
-	a.Bot.Handle(&a.btnRptDay, func(c telebot.Context) error { return a.renderReport(c, "day") })
-	a.Bot.Handle(&a.btnRptWeek, func(c telebot.Context) error { return a.renderReport(c, "week") })
-	a.Bot.Handle(&a.btnRptMonth, func(c telebot.Context) error { return a.renderReport(c, "month") })
-	a.Bot.Handle(&a.btnRptAll, func(c telebot.Context) error { return a.renderReport(c, "all") })
+	a.Bot.Handle(&a.btnRptDay, func(c telebot.Context) error { return a.cbReportPeriod(c, timeutil.PeriodDay) })
+	a.Bot.Handle(&a.btnRptWeek, func(c telebot.Context) error { return a.cbReportPeriod(c, timeutil.PeriodWeek) })
+	a.Bot.Handle(&a.btnRptMonth, func(c telebot.Context) error { return a.cbReportPeriod(c, timeutil.PeriodMonth) })
+	a.Bot.Handle(&a.btnRptAll, func(c telebot.Context) error { return a.cbReportPeriod(c, timeutil.PeriodAll) })
+	a.Bot.Handle(&a.btnRptNav, a.cbReportNav)
 	// per-task list
 	a.Bot.Handle(&a.btnTaskToggle, a.cbTaskToggle)
 	a.Bot.Handle(&a.btnTaskDelete, a.cbTaskDelete)
+	a.Bot.Handle(&a.btnTaskEdit, a.cbTaskEdit)
+	a.Bot.Handle(&a.btnEditField, a.cbEditField)
+	a.Bot.Handle(&a.btnRemindSet, a.cbRemindSet)
+	a.Bot.Handle(&a.btnRemindDone, a.cbRemindDone)
+	a.Bot.Handle(&a.btnTimerTask, a.cbTimerTask)
+	a.Bot.Handle(&a.btnTimerStop, a.cbTimerStop)
+	a.Bot.Handle(&a.btnExportFormat, a.cbExportFormat)
+	a.Bot.Handle(&a.btnExportGet, a.cbExportGet)
+	a.Bot.Handle(&a.btnICS, a.cbICS)
+	a.Bot.Handle(&a.btnBatchOK, a.cbBatchOK)
+	a.Bot.Handle(&a.btnBatchCancel, a.cbBatchCancel)
+	// archive
+	a.Bot.Handle(&a.btnArchiveOpen, a.cbArchiveOpen)
+	a.Bot.Handle(&a.btnArchiveRestore, a.cbArchiveRestore)
+	a.Bot.Handle(&a.btnArchivePurge, a.cbArchivePurge)
+	a.Bot.Handle(&a.btnArchivePurgeOK, a.cbArchivePurgeOK)
+	a.Bot.Handle(&a.btnArchiveKeep, a.cbArchiveKeep)
 	// post-add confirmation buttons
 	a.Bot.Handle(&a.btnAddAnother, a.cbAddAnother)
 	a.Bot.Handle(&a.btnStartControl, a.cbStartControl)
-
-	// text for add flow
+	// start/finish prompts sent by the scheduler
+	a.Bot.Handle(&telebot.Btn{Unique: notify.BtnStarted}, a.cbOccStarted)
+	a.Bot.Handle(&telebot.Btn{Unique: notify.BtnSnooze}, a.cbOccSnooze)
+	a.Bot.Handle(&telebot.Btn{Unique: notify.BtnSkip}, a.cbOccSkip)
+	a.Bot.Handle(&telebot.Btn{Unique: notify.BtnFinished}, a.cbOccFinished)
+	a.Bot.Handle(&telebot.Btn{Unique: notify.BtnEarlier}, a.cbOccEarlier)
+	a.Bot.Handle(&telebot.Btn{Unique: notify.BtnStillOn}, a.cbOccStillOn)
+
+	// text for add/edit flows
+	a.flow.On(stateAddTitle, a.onAddTitle)
+	a.flow.On(stateAddStart, a.onAddStart)
+	a.flow.On(stateAddEnd, a.onAddEnd)
+	a.flow.On(stateAddRepeat, a.onDaysText)
+	a.flow.On(stateAddDays, a.onDaysText)
+	a.flow.On(stateEditDays, a.onDaysText)
+	a.flow.On(stateAddDate, a.onDateText)
+	a.flow.On(stateEditDate, a.onDateText)
+	a.flow.On(stateRule, a.onRuleText)
+	a.flow.On(stateRuleLimit, a.onLimitText)
+	a.flow.On(stateRuleUntil, a.onUntilText)
+	a.flow.On(stateEditTitle, a.onEditTitle)
+	a.flow.On(stateEditStart, a.onEditStart)
+	a.flow.On(stateEditEnd, a.onEditEnd)
+	a.finishFlow.On(stateFinishTime, a.onFinishTime)
 	a.Bot.Handle(telebot.OnText, a.handleText)
+	a.Bot.Handle(telebot.OnDocument, a.handleDocument)
 }
 
 func (a *BotApp) handleAddStart(c telebot.Context, defaultTZ string) error {
 	_, _ = a.St.GetOrCreateUser(c.Sender().ID, defaultTZ)
-	a.addMu.Lock()
-	a.addState[c.Sender().ID] = &AddState{Step: 1}
-	a.addMu.Unlock()
-	return c.Send("Введите название задачи (например: Написать статью)")
+	if m := c.Message(); m != nil && strings.TrimSpace(m.Payload) != "" {
+		return a.handleQuick(c, m.Payload)
+	}
+	if err := a.flow.Start(c.Sender().ID, stateAddTitle, AddState{}); err != nil {
+		log.Println("flow start:", err)
+		return c.Send("Ошибка")
+	}
+	return c.Send("Введите название задачи (например: Написать статью)\nМожно сразу со временем и днями: Стендап с 9 до 9:15 по будням\n\nИли сразу несколько задач, по строке на каждую: 09:00-10:30 Стендап пн-пт")
+}
+
+func (a *BotApp) handleCancel(c telebot.Context) error {
+	s, err := a.flow.Get(c.Sender().ID)
+	if err != nil || s == nil {
+		return c.Send("Нечего отменять.")
+	}
+	if err := a.flow.Reset(c.Sender().ID); err != nil {
+		return c.Send("Ошибка")
+	}
+	return c.Send("Отменено.")
 }
 
 func (a *BotApp) handleText(c telebot.Context) error {
@@ -183,40 +435,99 @@ func (a *BotApp) handleText(c telebot.Context) error {
 	if text == "" {
 		return nil
 	}
-	a.addMu.Lock()
-	st, ok := a.addState[c.Sender().ID]
-	a.addMu.Unlock()
-	if !ok {
-		return nil
-	}
-	switch st.Step {
-	case 1:
-		st.Title = text
-		st.Step = 2
-		return c.Send("Время начала (HH:MM), например 09:30")
-	case 2:
-		h, m, ok := timeutil.ParseHHMM(text)
-		if !ok {
-			return c.Send("Неверный формат. Введите время как HH:MM (пример: 09:30)")
-		}
-		st.StartH, st.StartM = h, m
-		st.Step = 3
-		return c.Send("Время окончания (HH:MM), например 11:00")
-	case 3:
-		h, m, ok := timeutil.ParseHHMM(text)
-		if !ok {
-			return c.Send("Неверный формат. Введите время как HH:MM (пример: 11:00)")
-		}
-		st.EndH, st.EndM = h, m
-		if st.EndH < st.StartH || (st.EndH == st.StartH && st.EndM <= st.StartM) {
-			return c.Send("Окончание должно быть позже начала. Введите снова время окончания (HH:MM)")
+	// the add prompt offers quick entry: lines like "09:00-10:30 Стендап пн-пт"
+	// instead of a title become a batch of tasks
+	if s, err := a.flow.Get(c.Sender().ID); err == nil && s != nil && s.State == stateAddTitle &&
+		(strings.Contains(text, "\n") || isQuickEntry(text)) {
+		if err := a.flow.Reset(c.Sender().ID); err != nil {
+			return c.Send("Ошибка")
 		}
-		st.Step = 4
-		return c.Send("Выберите повтор:", a.repMK)
+		return a.handleQuick(c, text)
+	}
+	handled, err := a.flow.Dispatch(c.Sender().ID, c)
+	if err == nil && !handled {
+		handled, err = a.finishFlow.Dispatch(c.Sender().ID, c)
+	}
+	if err == nil && !handled && isQuickEntry(c.Text()) {
+		err = a.handleQuick(c, c.Text())
+	}
+	if err != nil {
+		log.Println("flow:", err)
 	}
 	return nil
 }
 
+func (a *BotApp) onAddTitle(c telebot.Context, s *fsm.Session[AddState]) error {
+	text := strings.TrimSpace(c.Text())
+	// "Стендап с 9 до 9:15 по будням" fills the following steps as well
+	if r := when.Parse(text); r.HasStart && r.Rest != "" {
+		s.Data.Title = r.Rest
+		return a.continueAdd(c, s, r)
+	}
+	s.Data.Title = text
+	s.State = stateAddStart
+	return c.Send(startPrompt)
+}
+
+func (a *BotApp) onAddStart(c telebot.Context, s *fsm.Session[AddState]) error {
+	r := when.Parse(c.Text())
+	if !r.HasStart || r.Rest != "" {
+		return c.Send("Не понял время. " + startPrompt)
+	}
+	return a.continueAdd(c, s, r)
+}
+
+func (a *BotApp) onAddEnd(c telebot.Context, s *fsm.Session[AddState]) error {
+	h, m, ok := parseEnd(c.Text(), s.Data.StartH, s.Data.StartM)
+	if !ok {
+		return c.Send("Не понял время. " + endPrompt)
+	}
+	if h == s.Data.StartH && m == s.Data.StartM {
+		return c.Send("Окончание не может совпадать с началом. " + endPrompt)
+	}
+	s.Data.EndH, s.Data.EndM = h, m
+	s.State = stateAddRepeat
+	return c.Send("Выберите повтор:\n"+daysHint, a.repMK)
+}
+
+func (a *BotApp) onEditTitle(c telebot.Context, s *fsm.Session[AddState]) error {
+	s.Data.Title = strings.TrimSpace(c.Text())
+	s.State = fsm.Done
+	return a.finishEdit(c, s.Data)
+}
+
+func (a *BotApp) onEditStart(c telebot.Context, s *fsm.Session[AddState]) error {
+	// a range ("с 10 до 11") changes both ends
+	r := when.Parse(c.Text())
+	if !r.HasStart || r.Rest != "" {
+		return c.Send("Не понял время. " + startPrompt)
+	}
+	endH, endM := s.Data.EndH, s.Data.EndM
+	if end, ok := r.Until(); ok {
+		endH, endM = end.H, end.M
+	}
+	if r.Start.H == endH && r.Start.M == endM {
+		return c.Send("Начало не может совпадать с окончанием. " + startPrompt)
+	}
+	s.Data.StartH, s.Data.StartM = r.Start.H, r.Start.M
+	s.Data.EndH, s.Data.EndM = endH, endM
+	s.State = fsm.Done
+	return a.finishEdit(c, s.Data)
+}
+
+func (a *BotApp) onEditEnd(c telebot.Context, s *fsm.Session[AddState]) error {
+	h, m, ok := parseEnd(c.Text(), s.Data.StartH, s.Data.StartM)
+	if !ok {
+		return c.Send("Не понял время. " + endPrompt)
+	}
+	if h == s.Data.StartH && m == s.Data.StartM {
+		return c.Send("Окончание не может совпадать с началом. " + endPrompt)
+	}
+	s.Data.EndH, s.Data.EndM = h, m
+	s.State = fsm.Done
+	return a.finishEdit(c, s.Data)
+}
+
 // helpers
 func (a *BotApp) formatDays(mask int) string {
 	if mask == timeutil.MaskDaily() {
@@ -250,13 +561,24 @@ func (a *BotApp) formatDays(mask int) string {
 	if len(parts) == 0 {
 		return "-"
 	}
-	if len(parts) == 1 {
-		return "Сегодня (" + parts[0] + ")"
-	}
 	return strings.Join(parts, ", ")
 }
 
-func (a *BotApp) renderCustomDaysKeyboard(st *AddState) *telebot.ReplyMarkup {
+// taskDays is the "Дни: …" line of a task, "Дата: …" for a one-off task
+// or "Повтор: …" for a task with a recurrence rule.
+func (a *BotApp) taskDays(t store.Task) string {
+	if t.OnDate != nil {
+		return "Дата: " + formatDate(*t.OnDate)
+	}
+	if t.RRule != nil && t.RRuleStart != nil {
+		if text, ok := describeRule(*t.RRule, *t.RRuleStart); ok {
+			return "Повтор: " + text
+		}
+	}
+	return "Дни: " + a.formatDays(t.DaysMask)
+}
+
+func (a *BotApp) renderCustomDaysKeyboard(st AddState) *telebot.ReplyMarkup {
 	isOn := func(bit int) bool { return (st.DaysMask & bit) != 0 }
 	label := func(name string, on bool) string {
 		if on {
@@ -282,14 +604,37 @@ func (a *BotApp) buildTaskText(t store.Task) string {
 	if t.Enabled {
 		state = "ВКЛ"
 	}
-	return fmt.Sprintf("• %02d:%02d–%02d:%02d %s [%s]\nДни: %s", t.StartH, t.StartM, t.EndH, t.EndM, t.Title, state, a.formatDays(t.DaysMask))
+	next := ""
+	if t.Overnight() {
+		next = " (+1)"
+	}
+	text := fmt.Sprintf("• %02d:%02d–%02d:%02d%s %s [%s]\n%s", t.StartH, t.StartM, t.EndH, t.EndM, next, t.Title, state, a.taskDays(t))
+	if r := formatReminders(t); r != "" {
+		text += "\nНапоминания: " + r
+	}
+	return text
 }
 
 func (a *BotApp) buildTaskMarkup(t store.Task) *telebot.ReplyMarkup {
 	mk := &telebot.ReplyMarkup{}
 	bT := mk.Data("Вкл/Выкл", a.btnTaskToggle.Unique, fmt.Sprintf("%d", t.ID))
+	bE := mk.Data("Изменить", a.btnTaskEdit.Unique, fmt.Sprintf("%d", t.ID))
 	bD := mk.Data("Удалить", a.btnTaskDelete.Unique, fmt.Sprintf("%d", t.ID))
-	mk.Inline(mk.Row(bT, bD))
+	mk.Inline(mk.Row(bT, bE, bD))
+	return mk
+}
+
+func (a *BotApp) buildEditMarkup(t store.Task) *telebot.ReplyMarkup {
+	mk := &telebot.ReplyMarkup{}
+	field := func(label, name string) telebot.Btn {
+		return mk.Data(label, a.btnEditField.Unique, fmt.Sprintf("%d:%s", t.ID, name))
+	}
+	mk.Inline(
+		mk.Row(field("Название", "title"), field("Дни", "days")),
+		mk.Row(field("Начало", "start"), field("Окончание", "end")),
+		mk.Row(field("Дата", "date"), field("Повтор", "rule")),
+		mk.Row(field("Напоминания", "remind"), field("Пропуски", "skip")),
+	)
 	return mk
 }
 
@@ -297,10 +642,8 @@ func (a *BotApp) buildTaskMarkup(t store.Task) *telebot.ReplyMarkup {
 
 func (a *BotApp) cbRepeatChoice(c telebot.Context, kind string) error {
 	id := c.Sender().ID
-	a.addMu.Lock()
-	st, ok := a.addState[id]
-	a.addMu.Unlock()
-	if !ok {
+	s, err := a.flow.Get(id)
+	if err != nil || s == nil || s.State != stateAddRepeat {
 		return c.Respond()
 	}
 
@@ -310,49 +653,71 @@ func (a *BotApp) cbRepeatChoice(c telebot.Context, kind string) error {
 		if err != nil {
 			return c.Respond()
 		}
-		loc, err := time.LoadLocation(u.TZ)
+		s.Data.setDate(a.userNow(u))
+	case "date":
+		u, err := a.St.GetUserByTGID(id)
 		if err != nil {
 			return c.Respond()
 		}
-		now := time.Now().In(loc)
-		st.DaysMask = timeutil.WeekdayBit(now.Weekday())
-		return a.finishAdd(c)
+		s.State = stateAddDate
+		if err := a.flow.Set(id, s); err != nil {
+			return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+		}
+		today := timeutil.StartOfDay(a.userNow(u))
+		_ = c.Respond()
+		return c.Edit(chooseDateTitle, a.dateMarkup(today, today, s.Data))
+	case "rule":
+		return a.askRule(c, s)
 	case "daily":
-		st.DaysMask = timeutil.MaskDaily()
-		return a.finishAdd(c)
+		s.Data.DaysMask = timeutil.MaskDaily()
 	case "workdays":
-		st.DaysMask = timeutil.MaskWorkdays()
-		return a.finishAdd(c)
+		s.Data.DaysMask = timeutil.MaskWorkdays()
 	case "custom":
-		return c.Edit(chooseDaysTitle, a.renderCustomDaysKeyboard(st))
+		s.State = stateAddDays
+		if err := a.flow.Set(id, s); err != nil {
+			return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+		}
+		return c.Edit(chooseDaysTitle, a.renderCustomDaysKeyboard(s.Data))
+	default:
+		return c.Respond()
 	}
-	return c.Respond()
+	return a.askReminders(c, s, stateAddRemind)
 }
 
 func (a *BotApp) cbToggleDay(c telebot.Context, wd time.Weekday) error {
 	id := c.Sender().ID
-	a.addMu.Lock()
-	st, ok := a.addState[id]
-	if ok {
-		st.DaysMask ^= timeutil.WeekdayBit(wd)
-	}
-	a.addMu.Unlock()
-	if ok {
-		_ = c.Edit(chooseDaysTitle, a.renderCustomDaysKeyboard(st))
-		return c.Respond()
+	s, err := a.flow.Get(id)
+	if err != nil || s == nil || (s.State != stateAddDays && s.State != stateEditDays) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Нет активного добавления"})
 	}
-	return c.Respond(&telebot.CallbackResponse{Text: "Нет активного добавления"})
+	s.Data.DaysMask ^= timeutil.WeekdayBit(wd)
+	if err := a.flow.Set(id, s); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	_ = c.Edit(chooseDaysTitle, a.renderCustomDaysKeyboard(s.Data))
+	return c.Respond()
 }
 
 func (a *BotApp) cbDaysDone(c telebot.Context) error {
 	id := c.Sender().ID
-	a.addMu.Lock()
-	st, ok := a.addState[id]
-	a.addMu.Unlock()
-	if !ok || st.DaysMask == 0 {
+	s, err := a.flow.Get(id)
+	if err != nil || s == nil || (s.State != stateAddDays && s.State != stateEditDays) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Нет активного добавления"})
+	}
+	if s.Data.DaysMask == 0 {
 		return c.Respond(&telebot.CallbackResponse{Text: "Выберите хотя бы один день"})
 	}
-	return a.finishAdd(c)
+	if s.State == stateAddDays {
+		return a.askReminders(c, s, stateAddRemind)
+	}
+	// picking weekdays turns a one-off or rule task into a weekly one
+	s.Data.Date = ""
+	s.Data.clearRule()
+	if err := a.flow.Reset(id); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	_ = c.Respond()
+	return a.finishEdit(c, s.Data)
 }
 
 func (a *BotApp) cbTaskToggle(c telebot.Context) error {
@@ -389,12 +754,126 @@ func (a *BotApp) cbTaskDelete(c telebot.Context) error {
 	}
 	var taskID int64
 	fmt.Sscanf(c.Callback().Data, "%d", &taskID)
-	_ = a.St.DeleteTask(u.ID, taskID)
+	if err := a.St.ArchiveTask(u.ID, taskID, a.St.Clock.Now().UTC()); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
 	if u.ControlEnabled {
 		_ = a.Sch.ScheduleAllForUser(u)
 	}
 	_ = c.Delete()
-	return nil
+	return c.Respond(&telebot.CallbackResponse{Text: "Задача перемещена в архив (/archive)"})
+}
+
+// cbTaskEdit — показывает меню выбора поля для редактирования задачи
+func (a *BotApp) cbTaskEdit(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	var taskID int64
+	fmt.Sscanf(c.Callback().Data, "%d", &taskID)
+	t, err := a.St.GetTask(u.ID, taskID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Задача не найдена"})
+	}
+	_ = c.Respond()
+	return c.Send("✏️ Что изменить?\n"+a.buildTaskText(t), a.buildEditMarkup(t))
+}
+
+// cbEditField — запускает ввод нового значения выбранного поля
+func (a *BotApp) cbEditField(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	var taskID int64
+	var field string
+	parts := strings.SplitN(c.Callback().Data, ":", 2)
+	if len(parts) == 2 {
+		fmt.Sscanf(parts[0], "%d", &taskID)
+		field = parts[1]
+	}
+	t, err := a.St.GetTask(u.ID, taskID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Задача не найдена"})
+	}
+	if field == "skip" {
+		// skip dates need no conversation: the calendar toggles them
+		text, mk, err := a.holidayView(u, &t, timeutil.StartOfDay(a.userNow(u)))
+		if err != nil {
+			return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+		}
+		_ = c.Respond()
+		return c.Edit(text, mk)
+	}
+	st := draftFromTask(t)
+	var state, prompt string
+	switch field {
+	case "title":
+		state, prompt = stateEditTitle, "Введите новое название задачи"
+	case "start":
+		state, prompt = stateEditStart, fmt.Sprintf("Новое время начала (например 09:30 или «с 10 до 11»), сейчас %02d:%02d", t.StartH, t.StartM)
+	case "end":
+		state, prompt = stateEditEnd, fmt.Sprintf("Новое время окончания (например 18:00 или «на час»), сейчас %02d:%02d", t.EndH, t.EndM)
+	case "days":
+		state = stateEditDays
+	case "date":
+		state = stateEditDate
+	case "rule":
+		state = stateRule
+	case "remind":
+		state = stateEditRemind
+	default:
+		return c.Respond()
+	}
+	if err := a.flow.Start(c.Sender().ID, state, st); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	_ = c.Respond()
+	switch state {
+	case stateEditDays:
+		return c.Edit(chooseDaysTitle, a.renderCustomDaysKeyboard(st))
+	case stateEditDate:
+		today := timeutil.StartOfDay(a.userNow(u))
+		month := today
+		if d, err := time.ParseInLocation(timeutil.DateLayout, st.Date, today.Location()); err == nil && d.After(today) {
+			month = d
+		}
+		return c.Edit(chooseDateTitle, a.dateMarkup(month, today, st))
+	case stateRule:
+		return c.Edit(chooseRuleTitle, a.ruleMarkup(timeutil.StartOfDay(a.userNow(u))))
+	case stateEditRemind:
+		return c.Edit(a.remindTitle(u), a.renderRemindKeyboard(st))
+	}
+	return c.Send(prompt)
+}
+
+// finishEdit — сохраняет изменённую задачу и перепланирует контроль
+func (a *BotApp) finishEdit(c telebot.Context, st AddState) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Ошибка пользователя")
+	}
+	if st.EditID == 0 {
+		return c.Send("Отменено")
+	}
+
+	err = a.St.UpdateTask(st.task(u.ID))
+	if err != nil {
+		return c.Send("Не удалось сохранить задачу")
+	}
+	if u.ControlEnabled {
+		_ = a.Sch.ScheduleAllForUser(u)
+	}
+	t, err := a.St.GetTask(u.ID, st.EditID)
+	if err != nil {
+		return c.Send("Задача не найдена")
+	}
+	text := "✏️ Задача обновлена.\n" + a.buildTaskText(t)
+	if w := dstWarning(t, a.userNow(u)); w != "" {
+		text += "\n\n" + w
+	}
+	return c.Send(text, a.buildTaskMarkup(t))
 }
 
 // NEW: post-add callbacks
@@ -403,10 +882,10 @@ func (a *BotApp) cbTaskDelete(c telebot.Context) error {
 func (a *BotApp) cbAddAnother(c telebot.Context) error {
 	_ = c.Respond(&telebot.CallbackResponse{Text: "Добавляем ещё одну…", ShowAlert: false})
 	// Запускаем мастер добавления (как /add), без необходимости знать defaultTZ:
-	a.addMu.Lock()
-	a.addState[c.Sender().ID] = &AddState{Step: 1}
-	a.addMu.Unlock()
-	return c.Send("Введите название задачи (например: Написать статью)")
+	if err := a.flow.Start(c.Sender().ID, stateAddTitle, AddState{}); err != nil {
+		return c.Send("Ошибка")
+	}
+	return c.Send("Введите название задачи (например: Написать статью)\nМожно сразу со временем и днями: Стендап с 9 до 9:15 по будням\n\nИли сразу несколько задач, по строке на каждую: 09:00-10:30 Стендап пн-пт")
 }
 
 // cbStartControl — включает контроль (как /run) и помечает исходное сообщение
@@ -438,26 +917,13 @@ func (a *BotApp) cbStartControl(c telebot.Context) error {
 
 // command handlers
 
-func (a *BotApp) finishAdd(c telebot.Context) error {
-	id := c.Sender().ID
-	u, err := a.St.GetUserByTGID(id)
+func (a *BotApp) finishAdd(c telebot.Context, st AddState) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
 	if err != nil {
 		return c.Send("Ошибка пользователя")
 	}
-	a.addMu.Lock()
-	st := a.addState[id]
-	delete(a.addState, id)
-	a.addMu.Unlock()
-	if st == nil {
-		return c.Send("Отменено")
-	}
 
-	taskID, err := a.St.CreateTask(store.Task{
-		UserID: u.ID, Title: st.Title,
-		StartH: st.StartH, StartM: st.StartM,
-		EndH: st.EndH, EndM: st.EndM,
-		DaysMask: st.DaysMask,
-	})
+	taskID, err := a.St.CreateTask(st.task(u.ID))
 	if err != nil {
 		return c.Send("Не удалось сохранить задачу")
 	}
@@ -468,7 +934,11 @@ func (a *BotApp) finishAdd(c telebot.Context) error {
 	t, _ := a.St.GetTask(u.ID, taskID)
 
 	// Новое подтверждение с кнопками
-	msgText := "✅ Задача добавлена.\n" + a.buildTaskText(t) + "\n\nМожешь добавить ещё одну или сразу запустить контроль:"
+	msgText := "✅ Задача добавлена.\n" + a.buildTaskText(t)
+	if w := dstWarning(t, a.userNow(u)); w != "" {
+		msgText += "\n\n" + w
+	}
+	msgText += "\n\nМожешь добавить ещё одну или сразу запустить контроль:"
 	kb := &telebot.ReplyMarkup{}
 	bAdd := kb.Data("➕ Ещё задачу", a.btnAddAnother.Unique, "go")
 	bRun := kb.Data("▶️ Запустить контроль", a.btnStartControl.Unique, "go")
@@ -481,16 +951,23 @@ func (a *BotApp) handleList(c telebot.Context) error {
 	if err != nil {
 		return c.Send("Сначала /start")
 	}
+	_, _ = a.St.ArchivePastTasks(u.ID, a.userNow(u))
 	tasks, err := a.St.ListTasks(u.ID)
 	if err != nil {
 		return c.Send("Ошибка чтения задач")
 	}
-	if len(tasks) == 0 {
+	archived, _ := a.St.CountArchivedTasks(u.ID)
+	if len(tasks) == 0 && archived == 0 {
 		return c.Send("Задач пока нет. Нажми ➕ Добавить задачу")
 	}
 	for _, t := range tasks {
 		_ = c.Send(a.buildTaskText(t), a.buildTaskMarkup(t))
 	}
+	if archived > 0 {
+		mk := &telebot.ReplyMarkup{}
+		mk.Inline(mk.Row(mk.Data(fmt.Sprintf("🗄 Архив (%d)", archived), a.btnArchiveOpen.Unique, "go")))
+		return c.Send("Удалённые и прошедшие разовые задачи хранятся в архиве вместе с историей.", mk)
+	}
 	return nil
 }
 
@@ -538,58 +1015,8 @@ func (a *BotApp) handleTZ(c telebot.Context) error {
 		return c.Send("Ошибка сохранения TZ")
 	}
 	if u.ControlEnabled {
-		_ = a.Sch.ScheduleAllForUser(store.User{ID: u.ID, TGID: u.TGID, TZ: tz, ControlEnabled: true})
+		u.TZ = tz
+		_ = a.Sch.ScheduleAllForUser(u)
 	}
 	return c.Send("Тайм-зона обновлена: " + tz)
 }
-
-func (a *BotApp) handleReportMenu(c telebot.Context) error {
-	return c.Send("Выберите период отчёта:", a.reportMK)
-}
-
-func (a *BotApp) renderReport(c telebot.Context, period string) error {
-	u, err := a.St.GetUserByTGID(c.Sender().ID)
-	if err != nil {
-		return c.Send("Сначала /start")
-	}
-	fromUTC, toUTC, err := timeutil.RangeUTC(period, u.TZ)
-	if err != nil {
-		return c.Send("Ошибка тайм-зоны")
-	}
-	stats, err := a.St.GetStats(u.ID, fromUTC, toUTC)
-	if err != nil {
-		return c.Send("Ошибка отчёта")
-	}
-	if len(stats) == 0 {
-		switch period {
-		case "day":
-			return c.Edit("За сегодня пока нет данных.")
-		case "week":
-			return c.Edit("За эту неделю пока нет данных.")
-		case "month":
-			return c.Edit("За этот месяц пока нет данных.")
-		default:
-			return c.Edit("Данных пока нет.")
-		}
-	}
-	sort.Slice(stats, func(i, j int) bool { return stats[i].Seconds > stats[j].Seconds })
-	total := int64(0)
-	for _, s := range stats {
-		total += s.Seconds
-	}
-	var b strings.Builder
-	title := map[string]string{"day": "сегодня", "week": "неделю", "month": "месяц", "all": "всё время"}[period]
-	if title == "" {
-		title = "период"
-	}
-	fmt.Fprintf(&b, "Отчёт за %s:\n", title)
-	for _, s := range stats {
-		h := s.Seconds / 3600
-		m := (s.Seconds % 3600) / 60
-		fmt.Fprintf(&b, "• %s — %02dч %02dм\n", s.Title, h, m)
-	}
-	h := total / 3600
-	m := (total % 3600) / 60
-	fmt.Fprintf(&b, "\nИтого: %02dч %02dм", h, m)
-	return c.Edit(b.String())
-}
@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/fsm"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+	"github.com/okpulse/telegram-schedule-bot/internal/when"
+)
+
+const chooseDateTitle = "Выберите дату (или напишите: завтра, 25.10.2026)"
+
+// monthLayout is the month in calendar navigation data.
+const monthLayout = "2006-01"
+
+// calendarMarkup is an inline month grid. Days before minDay cannot be
+// picked; marked days get a check mark. Picking a day sends dayBtn with
+// the date, the arrows send navBtn with the month; a non-empty key is put
+// before both as "key:".
+func (a *BotApp) calendarMarkup(month, minDay time.Time, marked func(time.Time) bool, dayBtn, navBtn telebot.Btn, key string) *telebot.ReplyMarkup {
+	mk := &telebot.ReplyMarkup{}
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	noop := func(text string) telebot.Btn { return mk.Data(text, a.btnCalNoop.Unique, "-") }
+	if key != "" {
+		key += ":"
+	}
+
+	prev, next := noop(" "), mk.Data("▶️", navBtn.Unique, key+first.AddDate(0, 1, 0).Format(monthLayout))
+	if first.After(minDay) {
+		prev = mk.Data("◀️", navBtn.Unique, key+first.AddDate(0, -1, 0).Format(monthLayout))
+	}
+	rows := []telebot.Row{mk.Row(prev, noop(timeutil.PeriodRange(timeutil.PeriodMonth, first).Label()), next)}
+
+	var head telebot.Row
+	for i := 1; i <= 7; i++ {
+		head = append(head, noop(weekdayShort[i%7]))
+	}
+	rows = append(rows, head)
+
+	// Monday-first grid
+	var row telebot.Row
+	for i := 0; i < (int(first.Weekday())+6)%7; i++ {
+		row = append(row, noop(" "))
+	}
+	for d := first; d.Month() == first.Month(); d = d.AddDate(0, 0, 1) {
+		switch {
+		case d.Before(minDay):
+			row = append(row, noop("·"))
+		case marked != nil && marked(d):
+			row = append(row, mk.Data("✅"+fmt.Sprint(d.Day()), dayBtn.Unique, key+d.Format(timeutil.DateLayout)))
+		default:
+			row = append(row, mk.Data(fmt.Sprint(d.Day()), dayBtn.Unique, key+d.Format(timeutil.DateLayout)))
+		}
+		if len(row) == 7 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		for len(row) < 7 {
+			row = append(row, noop(" "))
+		}
+		rows = append(rows, row)
+	}
+	mk.Inline(rows...)
+	return mk
+}
+
+// dateMarkup is the date picker of the add and edit flows.
+func (a *BotApp) dateMarkup(month, today time.Time, st AddState) *telebot.ReplyMarkup {
+	return a.calendarMarkup(month, today, func(d time.Time) bool {
+		return d.Format(timeutil.DateLayout) == st.Date
+	}, a.btnCalDay, a.btnCalNav, "")
+}
+
+// parseDay reads a single date: "сегодня", "завтра", "25.10.2026",
+// "2026-10-25".
+func parseDay(text string, now time.Time) (time.Time, bool) {
+	if r := when.Parse(text); r.HasDay && r.Rest == "" && !r.HasStart {
+		return timeutil.AddDays(now, r.DayOffset), true
+	}
+	r, ok := timeutil.ParseRange(strings.TrimSpace(text), now)
+	if !ok || r.Kind == timeutil.PeriodAll || !r.To.Equal(timeutil.AddDays(r.From, 1)) {
+		return time.Time{}, false
+	}
+	return r.From, true
+}
+
+// setDate makes the draft a one-off task on day.
+func (st *AddState) setDate(day time.Time) {
+	st.clearRule()
+	st.Date = day.Format(timeutil.DateLayout)
+	st.DaysMask = timeutil.WeekdayBit(day.Weekday())
+}
+
+// pickDate stores the chosen date and moves on: to the reminders when
+// adding, to saving when editing. In the rule flow the date ends the rule.
+func (a *BotApp) pickDate(c telebot.Context, s *fsm.Session[AddState], day time.Time) error {
+	if s.State == stateRuleUntil {
+		return a.limitRule(c, s, 0, day)
+	}
+	s.Data.setDate(day)
+	if s.State == stateEditDate {
+		s.State = fsm.Done
+		if err := a.flow.Set(c.Sender().ID, s); err != nil {
+			return c.Send("Ошибка")
+		}
+		if c.Callback() != nil {
+			_ = c.Respond()
+		}
+		return a.finishEdit(c, s.Data)
+	}
+	return a.askReminders(c, s, stateAddRemind)
+}
+
+func (a *BotApp) cbCalendarDay(c telebot.Context) error {
+	s, err := a.flow.Get(c.Sender().ID)
+	if err != nil || s == nil || (s.State != stateAddDate && s.State != stateEditDate && s.State != stateRuleUntil) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Нет активного добавления"})
+	}
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	now := a.userNow(u)
+	day, err := time.ParseInLocation(timeutil.DateLayout, c.Callback().Data, now.Location())
+	if err != nil || day.Before(timeutil.StartOfDay(now)) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Эта дата уже прошла"})
+	}
+	return a.pickDate(c, s, day)
+}
+
+func (a *BotApp) cbCalendarNav(c telebot.Context) error {
+	s, err := a.flow.Get(c.Sender().ID)
+	if err != nil || s == nil || (s.State != stateAddDate && s.State != stateEditDate && s.State != stateRuleUntil) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Нет активного добавления"})
+	}
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	now := a.userNow(u)
+	month, err := time.ParseInLocation(monthLayout, c.Callback().Data, now.Location())
+	if err != nil {
+		return c.Respond()
+	}
+	_ = c.Respond()
+	_, err = a.Bot.EditReplyMarkup(c.Message(), a.dateMarkup(month, timeutil.StartOfDay(now), s.Data))
+	return err
+}
+
+// onDateText takes a typed date instead of a calendar button.
+func (a *BotApp) onDateText(c telebot.Context, s *fsm.Session[AddState]) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Ошибка пользователя")
+	}
+	now := a.userNow(u)
+	day, ok := parseDay(c.Text(), now)
+	if !ok {
+		return c.Send("Не понял дату. " + chooseDateTitle)
+	}
+	if day.Before(timeutil.StartOfDay(now)) {
+		return c.Send("Эта дата уже прошла. " + chooseDateTitle)
+	}
+	return a.pickDate(c, s, day)
+}
+
+// formatDate is "25.10.2026 (Вс)".
+func formatDate(date string) string {
+	d, err := time.Parse(timeutil.DateLayout, date)
+	if err != nil {
+		return date
+	}
+	return d.Format("02.01.2006") + " (" + weekdayShort[d.Weekday()] + ")"
+}
@@ -0,0 +1,180 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/chart"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// maxTimelineDays limits the timeline chart to a week.
+const maxTimelineDays = 7
+
+// maxCaptionLen keeps a photo caption below Telegram's 1024-character limit.
+const maxCaptionLen = 1000
+
+func clipCaption(s string) string {
+	r := []rune(s)
+	if len(r) <= maxCaptionLen {
+		return s
+	}
+	return string(r[:maxCaptionLen-1]) + "…"
+}
+
+// renderChart sends the report over r as a chart, with the text totals as
+// the caption.
+func (a *BotApp) renderChart(c telebot.Context, u store.User, r timeutil.Range, mode string) error {
+	mk := a.reportMarkup(r, mode)
+	stats, err := a.St.GetStats(u.ID, r.From.UTC(), r.To.UTC())
+	if err != nil {
+		return c.Send("Ошибка отчёта")
+	}
+	if len(stats) == 0 {
+		return a.showReport(c, fmt.Sprintf("За %s нет данных.", r.Label()), nil, mk)
+	}
+	sortStats(stats)
+	title := "Отчёт за " + r.Label()
+
+	var png []byte
+	switch mode {
+	case reportTimeline, reportHeatmap:
+		spans, err := a.runSpans(u, r)
+		if err != nil {
+			return c.Send("Ошибка отчёта")
+		}
+		if mode == reportTimeline {
+			png, err = timelineChart(title, r, stats, spans)
+		} else {
+			png, err = heatmapChart(title, r, spans)
+		}
+		if err != nil {
+			return c.Send("Ошибка графика")
+		}
+	default:
+		bars := make([]chart.Bar, len(stats))
+		for i, s := range stats {
+			bars[i] = chart.Bar{Label: s.Title, Seconds: s.Seconds}
+		}
+		if png, err = chart.Bars(title, bars); err != nil {
+			return c.Send("Ошибка графика")
+		}
+	}
+	return a.showReport(c, reportSummary(r, stats), png, mk)
+}
+
+// runSpan is a recorded run clipped to the report range, in local time.
+type runSpan struct {
+	Title    string
+	From, To time.Time
+}
+
+// runSpans returns the user's runs within r, titled the way GetStats does.
+func (a *BotApp) runSpans(u store.User, r timeutil.Range) ([]runSpan, error) {
+	tasks, err := a.St.AllTasks(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	titles := make(map[int64]string, len(tasks))
+	for _, t := range tasks {
+		titles[t.ID] = t.Title
+	}
+	runs, err := a.St.ListRuns(u.ID, r.From.UTC(), r.To.UTC())
+	if err != nil {
+		return nil, err
+	}
+	loc := r.From.Location()
+	now := a.St.Clock.Now().In(loc)
+	out := make([]runSpan, 0, len(runs))
+	for _, run := range runs {
+		s := runSpan{From: time.Unix(run.StartTs, 0).In(loc), To: now}
+		if run.EndTs != nil {
+			s.To = time.Unix(*run.EndTs, 0).In(loc)
+		}
+		switch {
+		case run.TaskID != nil:
+			s.Title = titles[*run.TaskID]
+		case run.Title != nil:
+			s.Title = *run.Title
+		}
+		if s.From.Before(r.From) {
+			s.From = r.From
+		}
+		if s.To.After(r.To) {
+			s.To = r.To
+		}
+		if s.To.After(s.From) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// timelineChart draws a strip per day of r; series follow the order of
+// stats so that colours match the bar chart.
+func timelineChart(title string, r timeutil.Range, stats []store.StatRow, spans []runSpan) ([]byte, error) {
+	series := make([]string, len(stats))
+	index := make(map[string]int, len(stats))
+	for i, s := range stats {
+		series[i] = s.Title
+		index[s.Title] = i
+	}
+	var days []chart.Day
+	for d := r.From; d.Before(r.To); d = timeutil.AddDays(d, 1) {
+		next := timeutil.AddDays(d, 1)
+		day := chart.Day{Label: weekdayShort[d.Weekday()] + " " + d.Format("02.01")}
+		for _, s := range spans {
+			from, to := s.From, s.To
+			if from.Before(d) {
+				from = d
+			}
+			if to.After(next) {
+				to = next
+			}
+			if to.After(from) {
+				day.Segments = append(day.Segments, chart.Segment{Series: index[s.Title], From: wallClock(from, d), To: wallClock(to, d)})
+			}
+		}
+		days = append(days, day)
+	}
+	return chart.Timeline(title, series, days)
+}
+
+// wallClock places t on the 24-hour strip of the day starting at day by its
+// wall clock reading, so that strips of 23- and 25-hour days line up; the
+// end of the day is 24h.
+func wallClock(t, day time.Time) time.Duration {
+	if !t.Before(timeutil.AddDays(day, 1)) {
+		return 24 * time.Hour
+	}
+	if t.Before(day) {
+		return 0
+	}
+	return timeutil.SinceMidnight(t)
+}
+
+// heatmapChart draws the calendar of r with the total time of each day.
+func heatmapChart(title string, r timeutil.Range, spans []runSpan) ([]byte, error) {
+	var seconds []int64
+	for d := r.From; d.Before(r.To); d = timeutil.AddDays(d, 1) {
+		next := timeutil.AddDays(d, 1)
+		var sum int64
+		for _, s := range spans {
+			from, to := s.From, s.To
+			if from.Before(d) {
+				from = d
+			}
+			if to.After(next) {
+				to = next
+			}
+			if to.After(from) {
+				sum += int64(to.Sub(from).Seconds())
+			}
+		}
+		seconds = append(seconds, sum)
+	}
+	return chart.Heatmap(title, r.From, seconds)
+}
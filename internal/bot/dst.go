@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// dstWarning tells the user when the task's start or end falls into a
+// daylight saving gap in the coming year, and when it will happen instead.
+// Empty if it never does.
+func dstWarning(t store.Task, now time.Time) string {
+	if when, ok := nextGap(t, now, t.StartH, t.StartM, 0); ok {
+		return fmt.Sprintf("⚠️ %s в %02d:%02d часы переводятся вперёд: такого времени не будет, задача начнётся в %s.",
+			formatDate(when.Format(timeutil.DateLayout)), t.StartH, t.StartM, when.Format("15:04"))
+	}
+	endDay := 0
+	if t.Overnight() {
+		endDay = 1
+	}
+	if when, ok := nextGap(t, now, t.EndH, t.EndM, endDay); ok {
+		return fmt.Sprintf("⚠️ %s в %02d:%02d часы переводятся вперёд: такого времени не будет, задача закончится в %s.",
+			formatDate(when.Format(timeutil.DateLayout)), t.EndH, t.EndM, when.Format("15:04"))
+	}
+	return ""
+}
+
+// nextGap finds the next day on which h:m is skipped and the task has an
+// occurrence starting dayOffset days earlier; it returns the time the event
+// actually happens that day.
+func nextGap(t store.Task, now time.Time, h, m, dayOffset int) (time.Time, bool) {
+	from := now
+	// at most one or two transitions a year
+	for i := 0; i < 3; i++ {
+		day, ok := timeutil.NextGap(from, h, m)
+		if !ok {
+			return time.Time{}, false
+		}
+		if t.RunsOn(timeutil.AddDays(day, -dayOffset)) {
+			return timeutil.DateTimeOn(day, h, m, 0), true
+		}
+		from = timeutil.AddDays(day, 1)
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/export"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// Export formats.
+const (
+	formatCSV  = "csv"
+	formatJSON = "json"
+)
+
+const exportTitle = "Экспорт задач и учтённого времени. Выберите формат и период:"
+
+// exportMarkup offers the formats (the chosen one marked) and periods; the
+// period buttons carry the range and format like report navigation does.
+func (a *BotApp) exportMarkup(u store.User, format string) *telebot.ReplyMarkup {
+	mk := &telebot.ReplyMarkup{}
+	label := func(name, f string) string {
+		if f == format {
+			return "✅ " + name
+		}
+		return name
+	}
+	now := a.userNow(u)
+	period := func(name string, r timeutil.Range) telebot.Btn {
+		return mk.Data(name, a.btnExportGet.Unique, reportData(r, format))
+	}
+	week := timeutil.PeriodRange(timeutil.PeriodWeek, now)
+	month := timeutil.PeriodRange(timeutil.PeriodMonth, now)
+	mk.Inline(
+		mk.Row(mk.Data(label("CSV", formatCSV), a.btnExportFormat.Unique, formatCSV), mk.Data(label("JSON", formatJSON), a.btnExportFormat.Unique, formatJSON)),
+		mk.Row(period("Неделя", week), period("Прошлая неделя", week.Shift(-1))),
+		mk.Row(period("Месяц", month), period("Прошлый месяц", month.Shift(-1))),
+		mk.Row(period("Всё время", timeutil.PeriodRange(timeutil.PeriodAll, now))),
+	)
+	return mk
+}
+
+// handleExport — /export [csv|json] [период]
+func (a *BotApp) handleExport(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Сначала /start")
+	}
+	payload := strings.TrimSpace(c.Message().Payload)
+	format := formatCSV
+	if rest, ok := cutWord(payload, formatJSON); ok {
+		format, payload = formatJSON, rest
+	} else if rest, ok := cutWord(payload, formatCSV); ok {
+		payload = rest
+	}
+	if payload == "" {
+		return c.Send(exportTitle, a.exportMarkup(u, format))
+	}
+	r, ok := timeutil.ParseRange(payload, a.userNow(u))
+	if !ok {
+		return c.Send("Не понял период. Пример: /export csv прошлый месяц или /export json 2026-09-01..2026-09-30")
+	}
+	return a.sendExport(c, u, r, format)
+}
+
+func (a *BotApp) cbExportFormat(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Сначала /start"})
+	}
+	_ = c.Respond()
+	return c.Edit(exportTitle, a.exportMarkup(u, c.Callback().Data))
+}
+
+func (a *BotApp) cbExportGet(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Сначала /start"})
+	}
+	r, format, ok := parseReportData(c.Callback().Data, userLocation(u))
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	_ = c.Respond(&telebot.CallbackResponse{Text: "Готовлю файл…"})
+	return a.sendExport(c, u, r, format)
+}
+
+// sendExport sends the tasks and the runs over r: two CSV files or one JSON.
+func (a *BotApp) sendExport(c telebot.Context, u store.User, r timeutil.Range, format string) error {
+	d := export.Data{Loc: userLocation(u), To: r.To}
+	if r.Kind != timeutil.PeriodAll {
+		d.From = r.From
+	}
+	var err error
+	if d.Tasks, err = a.St.AllTasks(u.ID); err != nil {
+		return c.Send("Ошибка экспорта")
+	}
+	if d.Runs, err = a.St.ListRuns(u.ID, r.From.UTC(), r.To.UTC()); err != nil {
+		return c.Send("Ошибка экспорта")
+	}
+	suffix := "all"
+	if r.Kind != timeutil.PeriodAll {
+		suffix = r.From.Format(timeutil.DateLayout) + "_" + r.To.AddDate(0, 0, -1).Format(timeutil.DateLayout)
+	}
+	caption := "Экспорт за " + r.Label()
+	now := a.St.Clock.Now()
+
+	if format == formatJSON {
+		var buf bytes.Buffer
+		if err := export.JSON(&buf, d, now); err != nil {
+			return c.Send("Ошибка экспорта")
+		}
+		return c.Send(document(buf.Bytes(), "schedule_"+suffix+".json", "application/json", caption))
+	}
+	var tasks, runs bytes.Buffer
+	if err := export.TasksCSV(&tasks, d); err != nil {
+		return c.Send("Ошибка экспорта")
+	}
+	if err := export.RunsCSV(&runs, d, now); err != nil {
+		return c.Send("Ошибка экспорта")
+	}
+	if err := c.Send(document(tasks.Bytes(), "tasks.csv", "text/csv", "Задачи")); err != nil {
+		return err
+	}
+	return c.Send(document(runs.Bytes(), "runs_"+suffix+".csv", "text/csv", caption))
+}
+
+func document(data []byte, name, mime, caption string) *telebot.Document {
+	return &telebot.Document{
+		File:     telebot.FromReader(bytes.NewReader(data)),
+		FileName: name,
+		MIME:     mime,
+		Caption:  caption,
+	}
+}
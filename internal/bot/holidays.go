@@ -0,0 +1,280 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/ical"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+const (
+	// maxHolidayList limits the upcoming dates listed under the calendar.
+	maxHolidayList = 15
+	// maxHolidayRange caps the days added by one /holiday range.
+	maxHolidayRange = 62
+)
+
+// holidayKey is the calendar key of the user's holidays (nil taskID, "0") or of a
+// task's skip dates.
+func holidayKey(taskID *int64) string {
+	if taskID == nil {
+		return "0"
+	}
+	return strconv.FormatInt(*taskID, 10)
+}
+
+// parseHolidayKey splits "taskID:value" callback data.
+func parseHolidayKey(data string) (taskID *int64, value string, ok bool) {
+	key, value, ok := strings.Cut(data, ":")
+	if !ok {
+		return nil, "", false
+	}
+	id, err := strconv.ParseInt(key, 10, 64)
+	if err != nil {
+		return nil, "", false
+	}
+	if id != 0 {
+		taskID = &id
+	}
+	return taskID, value, true
+}
+
+// holidayView is the text and calendar of the user's holidays (task nil) or
+// of a task's skip dates, opened on month.
+func (a *BotApp) holidayView(u store.User, task *store.Task, month time.Time) (string, *telebot.ReplyMarkup, error) {
+	var (
+		taskID *int64
+		b      strings.Builder
+	)
+	if task != nil {
+		taskID = &task.ID
+		fmt.Fprintf(&b, "🏖 Пропуски задачи «%s»: в отмеченные даты она не запускается.\n", task.Title)
+	} else {
+		b.WriteString("🏖 Выходные: в отмеченные даты задачи не запускаются.\n")
+	}
+	b.WriteString("Нажмите на дату, чтобы отметить её или снять отметку.\n")
+
+	today := timeutil.StartOfDay(a.userNow(u))
+	list, err := a.St.ListHolidays(u.ID, taskID, today.Format(timeutil.DateLayout))
+	if err != nil {
+		return "", nil, err
+	}
+	marked := make(map[string]bool, len(list))
+	for _, h := range list {
+		marked[h.Day] = true
+	}
+	if len(list) > 0 {
+		b.WriteString("\nБлижайшие:\n")
+		for i, h := range list {
+			if i == maxHolidayList {
+				fmt.Fprintf(&b, "… и ещё %d\n", len(list)-i)
+				break
+			}
+			b.WriteString("• " + formatDate(h.Day))
+			if h.Title != "" {
+				b.WriteString(" — " + h.Title)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if task == nil {
+		b.WriteString("\nДобавить текстом: /holiday 31.12.2026 или /holiday 2026-12-31..2027-01-08; убрать все: /holiday очистить. Можно прислать файл .ics с праздниками.")
+	}
+
+	mk := a.calendarMarkup(month, today, func(d time.Time) bool {
+		return marked[d.Format(timeutil.DateLayout)]
+	}, a.btnHolDay, a.btnHolNav, holidayKey(taskID))
+	return strings.TrimRight(b.String(), "\n"), mk, nil
+}
+
+// handleHoliday — /holiday [дата|период|очистить]: выходные дни пользователя
+func (a *BotApp) handleHoliday(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Сначала /start")
+	}
+	now := a.userNow(u)
+	today := timeutil.StartOfDay(now)
+	arg := strings.TrimSpace(c.Message().Payload)
+	switch {
+	case arg == "":
+	case strings.EqualFold(arg, "очистить") || strings.EqualFold(arg, "clear"):
+		n, err := a.St.ClearHolidays(u.ID, today.Format(timeutil.DateLayout))
+		if err != nil {
+			return c.Send("Ошибка сохранения")
+		}
+		a.rescheduleHolidays(u)
+		return c.Send(fmt.Sprintf("Удалено выходных: %d", n))
+	default:
+		r, ok := timeutil.ParseRange(arg, now)
+		if !ok || r.Kind == timeutil.PeriodAll {
+			return c.Send("Не понял дату. Пример: /holiday 31.12.2026 или /holiday 2026-12-31..2027-01-08")
+		}
+		if r.From.Before(today) {
+			r.From = today
+		}
+		added := 0
+		for d, i := r.From, 0; d.Before(r.To) && i < maxHolidayRange; d, i = timeutil.AddDays(d, 1), i+1 {
+			ok, err := a.St.AddHoliday(store.Holiday{UserID: u.ID, Day: d.Format(timeutil.DateLayout)})
+			if err != nil {
+				return c.Send("Ошибка сохранения")
+			}
+			if ok {
+				added++
+			}
+		}
+		if added == 0 && !r.From.Before(r.To) {
+			return c.Send("Эти даты уже прошли.")
+		}
+		a.rescheduleHolidays(u)
+		_ = c.Send(fmt.Sprintf("Добавлено выходных: %d", added))
+	}
+	text, mk, err := a.holidayView(u, nil, today)
+	if err != nil {
+		return c.Send("Ошибка чтения выходных")
+	}
+	return c.Send(text, mk)
+}
+
+// cbHolidayDay toggles a date in the holiday (or skip date) calendar.
+func (a *BotApp) cbHolidayDay(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	taskID, value, ok := parseHolidayKey(c.Callback().Data)
+	if !ok {
+		return c.Respond()
+	}
+	now := a.userNow(u)
+	day, err := time.ParseInLocation(timeutil.DateLayout, value, now.Location())
+	if err != nil || day.Before(timeutil.StartOfDay(now)) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Эта дата уже прошла"})
+	}
+	var task *store.Task
+	if taskID != nil {
+		t, err := a.St.GetTask(u.ID, *taskID)
+		if err != nil {
+			return c.Respond(&telebot.CallbackResponse{Text: "Задача не найдена"})
+		}
+		task = &t
+	}
+	on, err := a.St.ToggleHoliday(u.ID, taskID, value)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	a.rescheduleHolidays(u)
+	text, mk, err := a.holidayView(u, task, day)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	note := formatDate(value) + ": без задач"
+	if !on {
+		note = formatDate(value) + ": как обычно"
+	}
+	_ = c.Respond(&telebot.CallbackResponse{Text: note})
+	return c.Edit(text, mk)
+}
+
+// cbHolidayNav turns the holiday (or skip date) calendar to another month.
+func (a *BotApp) cbHolidayNav(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	taskID, value, ok := parseHolidayKey(c.Callback().Data)
+	if !ok {
+		return c.Respond()
+	}
+	month, err := time.ParseInLocation(monthLayout, value, userLocation(u))
+	if err != nil {
+		return c.Respond()
+	}
+	var task *store.Task
+	if taskID != nil {
+		t, err := a.St.GetTask(u.ID, *taskID)
+		if err != nil {
+			return c.Respond(&telebot.CallbackResponse{Text: "Задача не найдена"})
+		}
+		task = &t
+	}
+	_, mk, err := a.holidayView(u, task, month)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	_ = c.Respond()
+	_, err = a.Bot.EditReplyMarkup(c.Message(), mk)
+	return err
+}
+
+// holidayImport reports whether an uploaded calendar holds holidays rather
+// than tasks: the caption says so, or every event lasts all day.
+func holidayImport(caption string, events []ical.Event) bool {
+	caption = strings.ToLower(caption)
+	for _, w := range []string{"выходн", "праздн", "holiday", "отпуск"} {
+		if strings.Contains(caption, w) {
+			return true
+		}
+	}
+	for _, e := range events {
+		if !e.AllDay() {
+			return false
+		}
+	}
+	return true
+}
+
+// importHolidays adds the all-day events of an uploaded calendar as
+// holidays.
+func (a *BotApp) importHolidays(c telebot.Context, u store.User, events []ical.Event) error {
+	days, skipped := ical.Holidays(events, userLocation(u), a.userNow(u))
+	added := 0
+	for _, h := range days {
+		h.UserID = u.ID
+		ok, err := a.St.AddHoliday(h)
+		if err != nil {
+			return c.Send("Ошибка сохранения")
+		}
+		if ok {
+			added++
+		}
+	}
+	a.rescheduleHolidays(u)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🏖 Добавлено выходных: %d", added)
+	if dup := len(days) - added; dup > 0 {
+		fmt.Fprintf(&b, " (уже были: %d)", dup)
+	}
+	b.WriteString("\n")
+	if len(skipped) > 0 {
+		b.WriteString("\nНе добавлены:\n")
+		for _, s := range skipped {
+			title := s.Title
+			if title == "" {
+				title = "(без названия)"
+			}
+			line := fmt.Sprintf("• %s — %s\n", title, s.Reason)
+			if b.Len()+len(line) > maxReportLen {
+				b.WriteString("…\n")
+				break
+			}
+			b.WriteString(line)
+		}
+	}
+	b.WriteString("\nПосмотреть и поправить: /holiday")
+	return c.Send(b.String())
+}
+
+// rescheduleHolidays re-plans today's jobs after the holidays changed.
+func (a *BotApp) rescheduleHolidays(u store.User) {
+	if u.ControlEnabled {
+		_ = a.Sch.ScheduleAllForUser(u)
+	}
+}
@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/export"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+)
+
+// handleICS — /ics [история]: расписание для календаря (.ics)
+func (a *BotApp) handleICS(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Сначала /start")
+	}
+	switch strings.ToLower(strings.TrimSpace(c.Message().Payload)) {
+	case "":
+	case "история", "history", "runs":
+		return a.sendICS(c, u, true)
+	default:
+		return c.Send("Пример: /ics или /ics история")
+	}
+	mk := &telebot.ReplyMarkup{}
+	mk.Inline(
+		mk.Row(mk.Data("📅 Только расписание", a.btnICS.Unique, "plan")),
+		mk.Row(mk.Data("📅 Расписание и учтённое время", a.btnICS.Unique, "runs")),
+	)
+	return c.Send("Файл .ics можно импортировать в Google Calendar, Apple Calendar или Outlook. Добавить в него прошлые записи учёта времени?", mk)
+}
+
+func (a *BotApp) cbICS(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Сначала /start"})
+	}
+	_ = c.Respond(&telebot.CallbackResponse{Text: "Готовлю файл…"})
+	return a.sendICS(c, u, c.Callback().Data == "runs")
+}
+
+func (a *BotApp) sendICS(c telebot.Context, u store.User, runs bool) error {
+	d := export.Data{Loc: userLocation(u)}
+	var err error
+	if d.Tasks, err = a.St.AllTasks(u.ID); err != nil {
+		return c.Send("Ошибка экспорта")
+	}
+	if runs {
+		if d.Runs, err = a.St.ListRuns(u.ID, time.Unix(0, 0), a.St.Clock.Now()); err != nil {
+			return c.Send("Ошибка экспорта")
+		}
+	}
+	var buf bytes.Buffer
+	if err := export.ICS(&buf, d, a.St.Clock.Now(), runs); err != nil {
+		return c.Send("Ошибка экспорта")
+	}
+	return c.Send(document(buf.Bytes(), "schedule.ics", "text/calendar", "Расписание (повторы задач — еженедельные события)"))
+}
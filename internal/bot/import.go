@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/ical"
+)
+
+// maxImportSize limits uploaded calendar files (bytes).
+const maxImportSize = 2 << 20
+
+// handleDocument imports tasks, or holidays, from an uploaded .ics file.
+func (a *BotApp) handleDocument(c telebot.Context) error {
+	doc := c.Message().Document
+	if doc == nil {
+		return nil
+	}
+	if !strings.EqualFold(path.Ext(doc.FileName), ".ics") && doc.MIME != "text/calendar" {
+		return c.Send("Я умею импортировать только календари в формате .ics")
+	}
+	if doc.FileSize > maxImportSize {
+		return c.Send("Файл слишком большой (больше 2 МБ)")
+	}
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Сначала /start")
+	}
+	rc, err := a.Bot.File(&doc.File)
+	if err != nil {
+		return c.Send("Не удалось скачать файл")
+	}
+	defer rc.Close()
+	events, err := ical.Parse(rc)
+	if errors.Is(err, ical.ErrNotCalendar) {
+		return c.Send("Это не похоже на календарь .ics")
+	}
+	if err != nil {
+		return c.Send("Не удалось прочитать файл")
+	}
+	if len(events) == 0 {
+		return c.Send("В календаре нет событий")
+	}
+
+	if holidayImport(c.Message().Caption, events) {
+		return a.importHolidays(c, u, events)
+	}
+
+	tasks, skipped := ical.Tasks(events, userLocation(u), a.userNow(u))
+	drafts := make([]AddState, 0, len(tasks))
+	for _, t := range tasks {
+		d := draftFromTask(t.Task)
+		d.Skips = t.Skips
+		drafts = append(drafts, d)
+	}
+	drafts, problems := a.dropExisting(u.ID, drafts)
+	for _, s := range skipped {
+		title := s.Title
+		if title == "" {
+			title = "(без названия)"
+		}
+		problems = append(problems, fmt.Sprintf("%s — %s", title, s.Reason))
+	}
+	return a.sendBatchPreview(c, drafts, problems)
+}
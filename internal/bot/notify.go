@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/mail"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/notify"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+)
+
+const notifyUsage = "Добавить: /notify email you@example.com (придёт код, затем /notify confirm код), /notify webhook https://example.com/hook или /notify log; убрать: /notify off email"
+
+// codeInterval is how often a confirmation code may be mailed to a user.
+const codeInterval = 10 * time.Minute
+
+// channelNames are the /notify channel kinds in display order.
+var channelNames = []struct{ kind, name string }{
+	{store.ChannelEmail, "почта"},
+	{store.ChannelWebhook, "webhook"},
+	{store.ChannelLog, "журнал сервера"},
+}
+
+func (a *BotApp) channelAvailable(kind string) bool {
+	return a.Router != nil && a.Router.Available(kind)
+}
+
+// notifyView describes the user's notification channels.
+func (a *BotApp) notifyView(userID int64) string {
+	channels, _ := a.St.ListNotifyChannels(userID)
+	var b strings.Builder
+	b.WriteString("Уведомления приходят в Telegram")
+	for _, n := range channelNames {
+		for _, ch := range channels {
+			if ch.Kind != n.kind {
+				continue
+			}
+			fmt.Fprintf(&b, "\n• %s", n.name)
+			if ch.Target != "" {
+				b.WriteString(": " + ch.Target)
+			}
+			if !ch.Confirmed() {
+				b.WriteString(" (ждёт кода подтверждения)")
+			}
+			if !a.channelAvailable(ch.Kind) {
+				b.WriteString(" (не настроено на сервере)")
+			}
+		}
+	}
+	if len(channels) == 0 {
+		b.WriteString(" и больше никуда")
+	}
+	b.WriteString(".\n" + notifyUsage)
+	return b.String()
+}
+
+// handleNotify — /notify [email адрес|confirm код|webhook url|log|off канал]: дополнительные каналы уведомлений
+func (a *BotApp) handleNotify(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Сначала /start")
+	}
+	args := strings.Fields(c.Message().Payload)
+	if len(args) == 0 {
+		return c.Send(a.notifyView(u.ID))
+	}
+	kind := strings.ToLower(args[0])
+	if kind == "off" {
+		if len(args) < 2 {
+			return c.Send("Какой канал убрать? Пример: /notify off email")
+		}
+		if err := a.St.DeleteNotifyChannel(u.ID, strings.ToLower(args[1])); err != nil {
+			return c.Send("Ошибка сохранения")
+		}
+		return c.Send(a.notifyView(u.ID))
+	}
+	if kind == "confirm" {
+		if len(args) < 2 {
+			return c.Send("Укажите код из письма: /notify confirm 123456")
+		}
+		ok, err := a.St.ConfirmNotifyChannel(u.ID, store.ChannelEmail, args[1])
+		if err != nil {
+			return c.Send("Ошибка сохранения")
+		}
+		if !ok {
+			return c.Send("Неверный код. Запросите новый: /notify email адрес")
+		}
+		return c.Send("Почта подтверждена. " + a.notifyView(u.ID))
+	}
+	ch := store.NotifyChannel{UserID: u.ID, Kind: kind}
+	switch kind {
+	case store.ChannelEmail:
+		if len(args) < 2 {
+			return c.Send("Укажите адрес: /notify email you@example.com")
+		}
+		addr, err := mail.ParseAddress(args[1])
+		if err != nil {
+			return c.Send("Не похоже на адрес почты: " + args[1])
+		}
+		ch.Target = addr.Address
+	case store.ChannelWebhook:
+		if len(args) < 2 {
+			return c.Send("Укажите адрес: /notify webhook https://example.com/hook")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := notify.CheckWebhookURL(ctx, args[1]); errors.Is(err, notify.ErrPrivateAddress) {
+			return c.Send("Адрес должен быть публичным: локальные и внутренние сети не подходят.")
+		} else if err != nil {
+			return c.Send("Нужен доступный адрес http:// или https://")
+		}
+		ch.Target = args[1]
+	case store.ChannelLog:
+	default:
+		return c.Send(notifyUsage)
+	}
+	if !a.channelAvailable(kind) {
+		return c.Send("Этот канал не настроен на сервере.")
+	}
+	if kind == store.ChannelEmail {
+		return a.sendEmailCode(c, u, ch)
+	}
+	if err := a.St.SetNotifyChannel(ch); err != nil {
+		return c.Send("Ошибка сохранения")
+	}
+	return c.Send("Готово. " + a.notifyView(u.ID))
+}
+
+// sendEmailCode mails a confirmation code to the address; the channel is
+// saved unconfirmed until the user enters the code.
+func (a *BotApp) sendEmailCode(c telebot.Context, u store.User, ch store.NotifyChannel) error {
+	now := a.St.Clock.Now()
+	busy := fmt.Sprintf("Код уже отправлен. Новый можно запросить через %d мин.", int(codeInterval.Minutes()))
+	channels, _ := a.St.ListNotifyChannels(u.ID)
+	for _, old := range channels {
+		if old.Kind == ch.Kind && old.CodeSentSince(now.Add(-codeInterval)) {
+			return c.Send(busy)
+		}
+	}
+	a.codeMu.Lock()
+	if last, ok := a.codeSent[u.ID]; ok && now.Sub(last) < codeInterval {
+		a.codeMu.Unlock()
+		return c.Send(busy)
+	}
+	a.codeSent[u.ID] = now
+	a.codeMu.Unlock()
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return c.Send("Ошибка")
+	}
+	code, sentAt := fmt.Sprintf("%06d", n.Int64()), now.Unix()
+	ch.Code, ch.CodeSentAt = &code, &sentAt
+	if err := a.St.SetNotifyChannel(ch); err != nil {
+		return c.Send("Ошибка сохранения")
+	}
+	e := notify.ForUser(u, notify.KindNotice, now, fmt.Sprintf("Код подтверждения: %s\nЧтобы получать уведомления на этот адрес, отправьте боту /notify confirm %s", code, code))
+	if err := a.Router.For(ch).Notify(e); err != nil {
+		log.Println("email code:", err)
+		_ = a.St.DeleteNotifyChannel(u.ID, ch.Kind)
+		return c.Send("Не удалось отправить письмо на " + ch.Target)
+	}
+	return c.Send("На " + ch.Target + " отправлен код. Введите его: /notify confirm код")
+}
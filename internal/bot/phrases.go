@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/fsm"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+	"github.com/okpulse/telegram-schedule-bot/internal/when"
+)
+
+const (
+	startPrompt = "Время начала, например 09:30, «в 9 утра» или сразу «с 9 до 10:30 по будням»"
+	endPrompt   = "Время окончания, например 11:00, «до полудня» или «на 45 минут»"
+	daysHint    = "Или напишите дни: «пн-пт», «каждый вторник и четверг», «по выходным», «завтра»"
+)
+
+// phraseDays applies the days named in r to the draft: weekdays make a
+// repeating task, "сегодня"/"завтра" a one-off task on that date in the
+// user's time zone. It reports whether r named any days.
+func (a *BotApp) phraseDays(userID int64, r when.Result, st *AddState) bool {
+	if r.DaysMask != 0 {
+		st.DaysMask, st.Date = r.DaysMask, ""
+		st.clearRule()
+		return true
+	}
+	if !r.HasDay {
+		return false
+	}
+	u, err := a.St.GetUserByTGID(userID)
+	if err != nil {
+		return false
+	}
+	st.setDate(timeutil.AddDays(a.userNow(u), r.DayOffset))
+	return true
+}
+
+// continueAdd fills the add wizard from a parsed phrase and skips every step
+// the phrase already answered: "с 9 до 10 по будням" goes straight to the
+// reminders.
+func (a *BotApp) continueAdd(c telebot.Context, s *fsm.Session[AddState], r when.Result) error {
+	if !r.HasStart {
+		s.State = stateAddStart
+		return c.Send(startPrompt)
+	}
+	s.Data.StartH, s.Data.StartM = r.Start.H, r.Start.M
+	end, ok := r.Until()
+	if !ok {
+		s.State = stateAddEnd
+		return c.Send(fmt.Sprintf("Начало: %02d:%02d. %s", r.Start.H, r.Start.M, endPrompt))
+	}
+	if end == r.Start {
+		s.State = stateAddEnd
+		return c.Send("Окончание не может совпадать с началом. " + endPrompt)
+	}
+	s.Data.EndH, s.Data.EndM = end.H, end.M
+	if a.phraseDays(c.Sender().ID, r, &s.Data) {
+		return a.askReminders(c, s, stateAddRemind)
+	}
+	s.State = stateAddRepeat
+	return c.Send(fmt.Sprintf("%02d:%02d–%02d:%02d. Выберите повтор:\n%s", r.Start.H, r.Start.M, end.H, end.M, daysHint), a.repMK)
+}
+
+// parseEnd reads an end time given as a time ("18:00", "до 6 вечера") or as a
+// duration from start ("на 45 минут", "1h30m").
+func parseEnd(text string, startH, startM int) (h, m int, ok bool) {
+	if c, ok := when.ParseClock(text); ok {
+		return c.H, c.M, true
+	}
+	if d, ok := when.ParseDuration(text); ok && d < 24*time.Hour {
+		c := when.Clock{H: startH, M: startM}.Add(d)
+		return c.H, c.M, true
+	}
+	return 0, 0, false
+}
+
+// onDaysText takes typed days instead of the repeat or day buttons.
+func (a *BotApp) onDaysText(c telebot.Context, s *fsm.Session[AddState]) error {
+	r := when.Parse(c.Text())
+	if strings.TrimSpace(r.Rest) != "" || r.HasStart || !a.phraseDays(c.Sender().ID, r, &s.Data) {
+		return c.Send("Не понял дни. " + daysHint)
+	}
+	if s.State == stateEditDays {
+		s.State = fsm.Done
+		return a.finishEdit(c, s.Data)
+	}
+	return a.askReminders(c, s, stateAddRemind)
+}
@@ -0,0 +1,193 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/fsm"
+	"github.com/okpulse/telegram-schedule-bot/internal/notify"
+	"github.com/okpulse/telegram-schedule-bot/internal/scheduler"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+	"github.com/okpulse/telegram-schedule-bot/internal/when"
+)
+
+// stateFinishTime waits for the actual finish time after "Закончил раньше".
+const stateFinishTime = "finish:time"
+
+// maxConfirmTimeout caps /confirm waiting times (minutes).
+const maxConfirmTimeout = 180
+
+// FinishDraft identifies the occurrence whose finish time is being entered.
+type FinishDraft struct {
+	TaskID int64  `json:"task_id"`
+	Day    string `json:"day"`
+}
+
+// occurrenceCallback resolves the user and occurrence of a prompt button.
+func (a *BotApp) occurrenceCallback(c telebot.Context) (store.User, int64, string, bool) {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return u, 0, "", false
+	}
+	taskID, day, ok := notify.ParseOccurrenceData(c.Callback().Data)
+	return u, taskID, day, ok
+}
+
+// markPrompt appends the outcome to the prompt message and drops its buttons.
+func (a *BotApp) markPrompt(c telebot.Context, note string) error {
+	if m := c.Message(); m != nil {
+		_ = c.Edit(m.Text + "\n" + note)
+	}
+	return c.Respond()
+}
+
+func (a *BotApp) userNow(u store.User) time.Time {
+	return a.St.Clock.Now().In(userLocation(u))
+}
+
+func (a *BotApp) cbOccStarted(c telebot.Context) error {
+	u, taskID, day, ok := a.occurrenceCallback(c)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	now := a.userNow(u)
+	if err := a.Sch.ConfirmStart(u, taskID, day, now); errors.Is(err, store.ErrRunOpen) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Идёт таймер — сначала /timer stop", ShowAlert: true})
+	} else if errors.Is(err, scheduler.ErrStartSettled) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Этот старт уже учтён или устарел"})
+	} else if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	return a.markPrompt(c, "▶️ Начато в "+now.Format("15:04"))
+}
+
+func (a *BotApp) cbOccSnooze(c telebot.Context) error {
+	u, taskID, day, ok := a.occurrenceCallback(c)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	next, err := a.Sch.SnoozeStart(u, taskID, day)
+	if errors.Is(err, scheduler.ErrStartSettled) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Этот старт уже учтён или устарел"})
+	}
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	loc, _ := time.LoadLocation(u.TZ)
+	return a.markPrompt(c, "⏰ Отложено до "+next.In(loc).Format("15:04"))
+}
+
+func (a *BotApp) cbOccSkip(c telebot.Context) error {
+	u, taskID, day, ok := a.occurrenceCallback(c)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	o, err := a.St.GetOccurrence(u.ID, taskID, day)
+	if err == nil && (o.StartStatus == store.OccAuto || o.StartStatus == store.OccConfirmed) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Старт уже засчитан"})
+	}
+	if err := a.Sch.SkipStart(u, taskID, day); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	return a.markPrompt(c, "⏭ Пропущено")
+}
+
+func (a *BotApp) cbOccFinished(c telebot.Context) error {
+	u, taskID, day, ok := a.occurrenceCallback(c)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	now := a.userNow(u)
+	if err := a.Sch.ConfirmFinish(u, taskID, day, now); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	return a.markPrompt(c, "✅ Закончено в "+now.Format("15:04"))
+}
+
+func (a *BotApp) cbOccStillOn(c telebot.Context) error {
+	u, taskID, day, ok := a.occurrenceCallback(c)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	next, err := a.Sch.ExtendFinish(u, taskID, day)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	loc, _ := time.LoadLocation(u.TZ)
+	return a.markPrompt(c, "⏩ Продолжаем, спрошу снова в "+next.In(loc).Format("15:04"))
+}
+
+func (a *BotApp) cbOccEarlier(c telebot.Context) error {
+	_, taskID, day, ok := a.occurrenceCallback(c)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	if err := a.finishFlow.Start(c.Sender().ID, stateFinishTime, FinishDraft{TaskID: taskID, Day: day}); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	_ = c.Respond()
+	return c.Send("Во сколько закончили? (например 10:45)")
+}
+
+func (a *BotApp) onFinishTime(c telebot.Context, s *fsm.Session[FinishDraft]) error {
+	clock, ok := when.ParseClock(c.Text())
+	if !ok {
+		return c.Send("Не понял время. Введите, например, 10:45 или «без четверти 11»")
+	}
+	h, m := clock.H, clock.M
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Ошибка пользователя")
+	}
+	now := a.userNow(u)
+	at := timeutil.DateTimeOn(now, h, m, 0)
+	if at.After(now) {
+		at = timeutil.DateTimeOn(now, h, m, -1)
+	}
+	s.State = fsm.Done
+	if err := a.Sch.ConfirmFinish(u, s.Data.TaskID, s.Data.Day, at); err != nil {
+		return c.Send("Не удалось сохранить")
+	}
+	return c.Send("✅ Окончание записано: " + at.Format("15:04"))
+}
+
+// handleConfirm — /confirm [auto|manual] [минуты]: что делать без ответа на старт
+func (a *BotApp) handleConfirm(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Сначала /start")
+	}
+	describe := func(policy string, timeout int) string {
+		if policy == store.ConfirmManual {
+			return fmt.Sprintf("без ответа за %d мин старт не учитывается", timeout)
+		}
+		return fmt.Sprintf("без ответа за %d мин старт засчитывается по плану", timeout)
+	}
+	args := strings.Fields(c.Message().Payload)
+	if len(args) == 0 {
+		return c.Send("Сейчас: " + describe(u.ConfirmPolicy, u.ConfirmTimeout) +
+			".\nИзменить: /confirm auto 15 или /confirm manual 15 (минуты ожидания ответа). Окончание без ответа всегда закрывается по плану.")
+	}
+	policy := args[0]
+	if policy != store.ConfirmAuto && policy != store.ConfirmManual {
+		return c.Send("Пример: /confirm auto 15 или /confirm manual 10")
+	}
+	timeout := u.ConfirmTimeout
+	if len(args) > 1 {
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v < 1 || v > maxConfirmTimeout {
+			return c.Send(fmt.Sprintf("Время ожидания — от 1 до %d минут", maxConfirmTimeout))
+		}
+		timeout = v
+	}
+	if err := a.St.UpdateUserConfirm(u.ID, policy, timeout); err != nil {
+		return c.Send("Ошибка сохранения")
+	}
+	return c.Send("Готово: " + describe(policy, timeout) + ".")
+}
@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+	"github.com/okpulse/telegram-schedule-bot/internal/when"
+)
+
+// quickLine matches "09:00-10:30 Стендап пн-пт": a time range, then the
+// title with optional days at the end.
+var quickLine = regexp.MustCompile(`^(\d{1,2}[:.]\d{2})\s*[-–—]\s*(\d{1,2}[:.]\d{2})\s*(.*)$`)
+
+// quickStart tells quick-entry messages apart from other text.
+var quickStart = regexp.MustCompile(`^\s*\d{1,2}[:.]\d{2}\s*[-–—]`)
+
+const quickUsage = "Формат: 09:00-10:30 Название дни — по строке на задачу.\nДни: пн-пт, пн,ср,пт, каждый вторник и четверг, ежедневно, будни, выходные (по умолчанию — ежедневно)."
+
+// parseQuickLine turns one line into a task draft or explains what is wrong.
+func parseQuickLine(line string) (AddState, string) {
+	m := quickLine.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return AddState{}, "нет времени в начале строки (пример: 09:00-10:30)"
+	}
+	var d AddState
+	start, ok := when.ParseClock(m[1])
+	if !ok {
+		return AddState{}, "неверное время начала «" + m[1] + "»"
+	}
+	end, ok := when.ParseClock(m[2])
+	if !ok {
+		return AddState{}, "неверное время окончания «" + m[2] + "»"
+	}
+	d.StartH, d.StartM, d.EndH, d.EndM = start.H, start.M, end.H, end.M
+	if d.StartH == d.EndH && d.StartM == d.EndM {
+		return AddState{}, "окончание совпадает с началом"
+	}
+
+	// the days are the longest run of trailing words that parses as days
+	// ("пн, ср, пт", "каждый вторник и четверг"); at least one word stays
+	// for the title
+	words := strings.Fields(m[3])
+	d.DaysMask = timeutil.MaskDaily()
+	for n := len(words) - 1; n >= 1; n-- {
+		if mask, ok := when.ParseDays(strings.Join(words[len(words)-n:], " ")); ok {
+			d.DaysMask = mask
+			words = words[:len(words)-n]
+			break
+		}
+	}
+	d.Title = strings.Join(words, " ")
+	if d.Title == "" {
+		return AddState{}, "нет названия"
+	}
+	return d, ""
+}
+
+// isQuickEntry reports whether text looks like quick-entry lines.
+func isQuickEntry(text string) bool { return quickStart.MatchString(text) }
+
+// handleQuick parses every line and shows the batch preview; lines that
+// cannot be parsed are listed with the reason.
+func (a *BotApp) handleQuick(c telebot.Context, text string) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Сначала /start")
+	}
+	var (
+		drafts   []AddState
+		problems []string
+	)
+	for i, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		d, reason := parseQuickLine(line)
+		if reason != "" {
+			problems = append(problems, fmt.Sprintf("строка %d: %s", i+1, reason))
+			continue
+		}
+		drafts = append(drafts, d)
+	}
+	drafts, dups := a.dropExisting(u.ID, drafts)
+	problems = append(problems, dups...)
+	if len(drafts) == 0 && len(dups) == 0 {
+		problems = append(problems, quickUsage)
+	}
+	return a.sendBatchPreview(c, drafts, problems)
+}
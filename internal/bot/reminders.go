@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/fsm"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+)
+
+// reminderLeads are the lead times (minutes) offered on the keyboard.
+var reminderLeads = []int{5, 15, 30}
+
+// maxReminderLead caps /remind values at one day.
+const maxReminderLead = 24 * 60
+
+func formatLead(m int) string {
+	if m <= 0 {
+		return "нет"
+	}
+	return fmt.Sprintf("за %d мин", m)
+}
+
+// formatReminders describes reminders set on the task itself; "" when the
+// task uses the user's defaults.
+func formatReminders(t store.Task) string {
+	parts := []string{}
+	if t.RemindStart != nil {
+		parts = append(parts, "до начала "+formatLead(*t.RemindStart))
+	}
+	if t.RemindEnd != nil {
+		parts = append(parts, "до окончания "+formatLead(*t.RemindEnd))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (a *BotApp) remindTitle(u store.User) string {
+	return fmt.Sprintf("Напоминания: 1-й ряд — до начала, 2-й — до окончания.\nПо умолчанию: до начала %s, до окончания %s (/remind).",
+		formatLead(u.RemindStart), formatLead(u.RemindEnd))
+}
+
+func (a *BotApp) renderRemindKeyboard(st AddState) *telebot.ReplyMarkup {
+	mk := &telebot.ReplyMarkup{}
+	row := func(kind string, cur *int) telebot.Row {
+		label := func(name string, on bool) string {
+			if on {
+				return "✅ " + name
+			}
+			return name
+		}
+		btns := []telebot.Btn{
+			mk.Data(label("Умолч.", cur == nil), a.btnRemindSet.Unique, kind+":-1"),
+			mk.Data(label("Нет", cur != nil && *cur == 0), a.btnRemindSet.Unique, kind+":0"),
+		}
+		for _, m := range reminderLeads {
+			btns = append(btns, mk.Data(label(fmt.Sprintf("%dм", m), cur != nil && *cur == m), a.btnRemindSet.Unique, fmt.Sprintf("%s:%d", kind, m)))
+		}
+		return mk.Row(btns...)
+	}
+	bDone := mk.Data("Готово", a.btnRemindDone.Unique, "done")
+	mk.Inline(row("s", st.RemindStart), row("e", st.RemindEnd), mk.Row(bDone))
+	return mk
+}
+
+// askReminders moves the flow to the reminder step and shows its keyboard.
+func (a *BotApp) askReminders(c telebot.Context, s *fsm.Session[AddState], state string) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	s.State = state
+	if err := a.flow.Set(c.Sender().ID, s); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	if c.Callback() == nil {
+		// the previous step was typed, there is no keyboard to replace
+		return c.Send(a.remindTitle(u), a.renderRemindKeyboard(s.Data))
+	}
+	_ = c.Respond()
+	return c.Edit(a.remindTitle(u), a.renderRemindKeyboard(s.Data))
+}
+
+func (a *BotApp) cbRemindSet(c telebot.Context) error {
+	id := c.Sender().ID
+	s, err := a.flow.Get(id)
+	if err != nil || s == nil || (s.State != stateAddRemind && s.State != stateEditRemind) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Нет активного добавления"})
+	}
+	kind, val, _ := strings.Cut(c.Callback().Data, ":")
+	m, err := strconv.Atoi(val)
+	if err != nil {
+		return c.Respond()
+	}
+	var lead *int
+	if m >= 0 {
+		lead = &m
+	}
+	switch kind {
+	case "s":
+		s.Data.RemindStart = lead
+	case "e":
+		s.Data.RemindEnd = lead
+	default:
+		return c.Respond()
+	}
+	if err := a.flow.Set(id, s); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	_ = c.Edit(c.Message().Text, a.renderRemindKeyboard(s.Data))
+	return c.Respond()
+}
+
+func (a *BotApp) cbRemindDone(c telebot.Context) error {
+	id := c.Sender().ID
+	s, err := a.flow.Get(id)
+	if err != nil || s == nil || (s.State != stateAddRemind && s.State != stateEditRemind) {
+		return c.Respond(&telebot.CallbackResponse{Text: "Нет активного добавления"})
+	}
+	if err := a.flow.Reset(id); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	_ = c.Respond()
+	if s.State == stateEditRemind {
+		return a.finishEdit(c, s.Data)
+	}
+	return a.finishAdd(c, s.Data)
+}
+
+// handleRemind — /remind [до_начала] [до_окончания]: напоминания по умолчанию
+func (a *BotApp) handleRemind(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Сначала /start")
+	}
+	args := strings.Fields(c.Message().Payload)
+	if len(args) == 0 {
+		return c.Send(fmt.Sprintf("Напоминания по умолчанию: до начала %s, до окончания %s.\nИзменить: /remind 15 5 (минуты до начала и до окончания, 0 — выключить).",
+			formatLead(u.RemindStart), formatLead(u.RemindEnd)))
+	}
+	vals := []int{0, 0}
+	for i := 0; i < len(args) && i < 2; i++ {
+		v, err := strconv.Atoi(args[i])
+		if err != nil || v < 0 || v > maxReminderLead {
+			return c.Send("Неверное значение. Пример: /remind 15 5")
+		}
+		vals[i] = v
+	}
+	if err := a.St.UpdateUserReminders(u.ID, vals[0], vals[1]); err != nil {
+		return c.Send("Ошибка сохранения")
+	}
+	u.RemindStart, u.RemindEnd = vals[0], vals[1]
+	if u.ControlEnabled {
+		_ = a.Sch.ScheduleAllForUser(u)
+	}
+	return c.Send(fmt.Sprintf("Напоминания по умолчанию: до начала %s, до окончания %s.", formatLead(u.RemindStart), formatLead(u.RemindEnd)))
+}
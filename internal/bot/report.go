@@ -0,0 +1,301 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/scheduler"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// maxDailyDays limits the per-day breakdown to about two months.
+const maxDailyDays = 62
+
+// maxReportLen keeps a report below Telegram's 4096-character message limit.
+const maxReportLen = 3900
+
+const reportUsage = "Выберите период отчёта или укажите его командой:\n/report вчера, /report прошлая неделя, /report прошлый месяц\n/report 2026-09-01..2026-09-30\n/report план неделя — сравнение с расписанием"
+
+// Report views; the mode travels in the navigation callback data.
+const (
+	reportTotals = "t" // time per task
+	reportDaily  = "d" // plus a per-day breakdown
+	reportPlan   = "p" // planned versus actual
+	// charts, sent as a photo with the totals as caption
+	reportBars     = "b"
+	reportTimeline = "l"
+	reportHeatmap  = "h"
+)
+
+var weekdayShort = []string{"Вс", "Пн", "Вт", "Ср", "Чт", "Пт", "Сб"}
+
+// reportData encodes a range and the report mode into callback data.
+func reportData(r timeutil.Range, mode string) string {
+	return strings.Join([]string{r.Kind, r.From.Format(timeutil.DateLayout), r.To.Format(timeutil.DateLayout), mode}, ":")
+}
+
+// parseReportData is the inverse of reportData.
+func parseReportData(data string, loc *time.Location) (timeutil.Range, string, bool) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 4 {
+		return timeutil.Range{}, "", false
+	}
+	from, err1 := time.ParseInLocation(timeutil.DateLayout, parts[1], loc)
+	to, err2 := time.ParseInLocation(timeutil.DateLayout, parts[2], loc)
+	if err1 != nil || err2 != nil || !from.Before(to) {
+		return timeutil.Range{}, "", false
+	}
+	return timeutil.Range{Kind: parts[0], From: from, To: to}, parts[3], true
+}
+
+func userLocation(u store.User) *time.Location {
+	loc, err := time.LoadLocation(u.TZ)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// handleReportMenu — /report [период]: без аргумента показывает меню
+func (a *BotApp) handleReportMenu(c telebot.Context) error {
+	payload := ""
+	if m := c.Message(); m != nil {
+		payload = strings.TrimSpace(m.Payload)
+	}
+	if payload == "" {
+		return c.Send(reportUsage, a.reportMK)
+	}
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Сначала /start")
+	}
+	mode := reportTotals
+	if rest, ok := cutWord(payload, "план", "plan"); ok {
+		mode, payload = reportPlan, rest
+		if payload == "" {
+			payload = "неделя"
+		}
+	}
+	r, ok := timeutil.ParseRange(payload, a.userNow(u))
+	if !ok {
+		return c.Send("Не понял период.\n" + reportUsage)
+	}
+	return a.renderReport(c, u, r, mode)
+}
+
+func (a *BotApp) cbReportPeriod(c telebot.Context, kind string) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Сначала /start"})
+	}
+	_ = c.Respond()
+	return a.renderReport(c, u, timeutil.PeriodRange(kind, a.userNow(u)), reportTotals)
+}
+
+func (a *BotApp) cbReportNav(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Сначала /start"})
+	}
+	r, mode, ok := parseReportData(c.Callback().Data, userLocation(u))
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	_ = c.Respond()
+	return a.renderReport(c, u, r, mode)
+}
+
+// cutWord strips a leading keyword (any of words, case-insensitive).
+func cutWord(s string, words ...string) (string, bool) {
+	first, rest, _ := strings.Cut(s, " ")
+	for _, w := range words {
+		if strings.EqualFold(first, w) {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return s, false
+}
+
+func (a *BotApp) reportMarkup(r timeutil.Range, mode string) *telebot.ReplyMarkup {
+	mk := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	if r.Kind != timeutil.PeriodAll {
+		rows = append(rows, mk.Row(
+			mk.Data("◀️", a.btnRptNav.Unique, reportData(r.Shift(-1), mode)),
+			mk.Data("▶️", a.btnRptNav.Unique, reportData(r.Shift(1), mode)),
+		))
+	}
+	var views []telebot.Btn
+	if mode != reportTotals {
+		views = append(views, mk.Data("📊 Итоги", a.btnRptNav.Unique, reportData(r, reportTotals)))
+	}
+	if mode != reportDaily && r.Kind != timeutil.PeriodAll && r.Days() > 1 && r.Days() <= maxDailyDays {
+		views = append(views, mk.Data("📅 По дням", a.btnRptNav.Unique, reportData(r, reportDaily)))
+	}
+	if mode != reportPlan && r.Kind != timeutil.PeriodAll {
+		views = append(views, mk.Data("🎯 План/факт", a.btnRptNav.Unique, reportData(r, reportPlan)))
+	}
+	rows = append(rows, mk.Row(views...))
+	var charts []telebot.Btn
+	if mode != reportBars {
+		charts = append(charts, mk.Data("📊 График", a.btnRptNav.Unique, reportData(r, reportBars)))
+	}
+	if mode != reportTimeline && r.Kind != timeutil.PeriodAll && r.Days() <= maxTimelineDays {
+		charts = append(charts, mk.Data("🕒 Таймлайн", a.btnRptNav.Unique, reportData(r, reportTimeline)))
+	}
+	if mode != reportHeatmap && r.Kind != timeutil.PeriodAll && r.Days() > 1 && r.Days() <= maxDailyDays {
+		charts = append(charts, mk.Data("🔥 Календарь", a.btnRptNav.Unique, reportData(r, reportHeatmap)))
+	}
+	rows = append(rows, mk.Row(charts...))
+	mk.Inline(rows...)
+	return mk
+}
+
+// renderReport shows time per task over r (optionally split by day, compared
+// with the plan or drawn as a chart) and replaces the menu message when
+// called from a button.
+func (a *BotApp) renderReport(c telebot.Context, u store.User, r timeutil.Range, mode string) error {
+	send := func(text string) error {
+		return a.showReport(c, text, nil, a.reportMarkup(r, mode))
+	}
+	switch mode {
+	case reportBars, reportTimeline, reportHeatmap:
+		return a.renderChart(c, u, r, mode)
+	case reportPlan:
+		if r.Kind == timeutil.PeriodAll {
+			// there is no plan to speak of since the epoch
+			mode = reportTotals
+			break
+		}
+		rows, err := a.Sch.Adherence(u, r)
+		if err != nil {
+			return c.Send("Ошибка отчёта")
+		}
+		return send(formatAdherence(r, rows))
+	}
+	stats, err := a.St.GetStats(u.ID, r.From.UTC(), r.To.UTC())
+	if err != nil {
+		return c.Send("Ошибка отчёта")
+	}
+	if len(stats) == 0 {
+		return send(fmt.Sprintf("За %s нет данных.", r.Label()))
+	}
+	sortStats(stats)
+	var b strings.Builder
+	b.WriteString(reportSummary(r, stats))
+	if mode == reportDaily && r.Days() <= maxDailyDays {
+		b.WriteString("\n\nПо дням:")
+		for d := r.From; d.Before(r.To); d = timeutil.AddDays(d, 1) {
+			day, err := a.St.GetStats(u.ID, d.UTC(), timeutil.AddDays(d, 1).UTC())
+			if err != nil {
+				return c.Send("Ошибка отчёта")
+			}
+			sum := int64(0)
+			for _, s := range day {
+				sum += s.Seconds
+			}
+			if sum == 0 {
+				continue
+			}
+			sortStats(day)
+			line := fmt.Sprintf("\n%s %s — %s", weekdayShort[d.Weekday()], d.Format("02.01"), formatHM(sum))
+			for _, s := range day {
+				if s.Seconds > 0 {
+					line += fmt.Sprintf("\n   %s — %s", s.Title, formatHM(s.Seconds))
+				}
+			}
+			if b.Len()+len(line) > maxReportLen {
+				b.WriteString("\n…")
+				break
+			}
+			b.WriteString(line)
+		}
+	}
+	return send(b.String())
+}
+
+// reportSummary lists time per task (sorted) and the total.
+func reportSummary(r timeutil.Range, stats []store.StatRow) string {
+	total := int64(0)
+	for _, s := range stats {
+		total += s.Seconds
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Отчёт за %s:\n", r.Label())
+	for _, s := range stats {
+		fmt.Fprintf(&b, "• %s — %s\n", s.Title, formatHM(s.Seconds))
+	}
+	fmt.Fprintf(&b, "\nИтого: %s", formatHM(total))
+	return b.String()
+}
+
+// showReport sends a text or photo report; from a button it replaces the
+// message the button belongs to (a photo cannot become text and back, so
+// such messages are deleted and sent anew).
+func (a *BotApp) showReport(c telebot.Context, text string, photo []byte, mk *telebot.ReplyMarkup) error {
+	m := c.Message()
+	fromButton := c.Callback() != nil && m != nil
+	if photo != nil {
+		p := &telebot.Photo{File: telebot.FromReader(bytes.NewReader(photo)), Caption: clipCaption(text)}
+		if fromButton && m.Photo != nil {
+			return c.Edit(p, mk)
+		}
+		if fromButton {
+			_ = c.Delete()
+		}
+		return c.Send(p, mk)
+	}
+	if fromButton && m.Photo == nil {
+		return c.Edit(text, mk)
+	}
+	if fromButton {
+		_ = c.Delete()
+	}
+	return c.Send(text, mk)
+}
+
+func sortStats(stats []store.StatRow) {
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Seconds > stats[j].Seconds })
+}
+
+// formatAdherence renders the planned-versus-actual report.
+func formatAdherence(r timeutil.Range, rows []scheduler.AdherenceRow) string {
+	if len(rows) == 0 {
+		return fmt.Sprintf("За %s по расписанию ничего не запланировано.", r.Label())
+	}
+	var b strings.Builder
+	var total scheduler.AdherenceRow
+	fmt.Fprintf(&b, "План/факт за %s:\n", r.Label())
+	for _, row := range rows {
+		if row.Occurrences == 0 {
+			fmt.Fprintf(&b, "\n• %s — вне плана %s\n", row.Title, formatHM(row.Unplanned))
+			continue
+		}
+		fmt.Fprintf(&b, "\n• %s — %d%% (план %s, факт %s)\n", row.Title, row.Percent(), formatHM(row.Planned), formatHM(row.Actual))
+		notes := []string{fmt.Sprintf("пропущено %d из %d", row.Missed, row.Occurrences)}
+		if row.Late > 0 {
+			notes = append(notes, fmt.Sprintf("опозданий %d", row.Late))
+		}
+		if row.Overruns > 0 {
+			notes = append(notes, fmt.Sprintf("переработок %d", row.Overruns))
+		}
+		if row.Unplanned > 0 {
+			notes = append(notes, "вне плана "+formatHM(row.Unplanned))
+		}
+		b.WriteString("   " + strings.Join(notes, " · ") + "\n")
+		total.Planned += row.Planned
+		total.Covered += row.Covered
+		total.Actual += row.Actual
+	}
+	if total.Planned > 0 {
+		fmt.Fprintf(&b, "\nИтого: %d%% (план %s, факт %s)", total.Percent(), formatHM(total.Planned), formatHM(total.Actual))
+	}
+	fmt.Fprintf(&b, "\nОпоздание или переработка — больше %d мин.", int(scheduler.LateGrace.Minutes()))
+	return b.String()
+}
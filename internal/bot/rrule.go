@@ -0,0 +1,233 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/fsm"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// Recurrence rule states, shared by the add wizard and the edit flow
+// (AddState.EditID tells them apart).
+const (
+	stateRule      = "rule:pick"
+	stateRuleLimit = "rule:limit"
+	stateRuleUntil = "rule:until"
+)
+
+const (
+	chooseRuleTitle  = "Как повторять?\nИли пришлите правило RRULE, например FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"
+	chooseLimitTitle = "Сколько раз повторять? Можно написать число раз или дату окончания."
+)
+
+// rulePresets are the common patterns offered for a rule starting on day.
+func rulePresets(day time.Time) []timeutil.Recurrence {
+	wd := day.Weekday()
+	nth := (day.Day()-1)/7 + 1
+	presets := []timeutil.Recurrence{
+		{Freq: timeutil.FreqWeekly, Interval: 2, ByDay: []timeutil.WeekdayNum{{Day: wd}}},
+		{Freq: timeutil.FreqMonthly, ByDay: []timeutil.WeekdayNum{{N: 1, Day: time.Monday}}},
+	}
+	if nth != 1 || wd != time.Monday {
+		presets = append(presets, timeutil.Recurrence{Freq: timeutil.FreqMonthly, ByDay: []timeutil.WeekdayNum{{N: nth, Day: wd}}})
+	}
+	return append(presets,
+		timeutil.Recurrence{Freq: timeutil.FreqMonthly, ByMonthDay: []int{day.Day()}},
+		timeutil.Recurrence{Freq: timeutil.FreqMonthly, ByMonthDay: []int{-1}},
+		timeutil.Recurrence{Freq: timeutil.FreqYearly},
+	)
+}
+
+func (a *BotApp) ruleMarkup(today time.Time) *telebot.ReplyMarkup {
+	mk := &telebot.ReplyMarkup{}
+	var rows []telebot.Row
+	for _, r := range rulePresets(today) {
+		rows = append(rows, mk.Row(mk.Data(r.Describe(today), a.btnRulePreset.Unique, r.String())))
+	}
+	mk.Inline(rows...)
+	return mk
+}
+
+func (a *BotApp) limitMarkup() *telebot.ReplyMarkup {
+	mk := &telebot.ReplyMarkup{}
+	limit := func(text, data string) telebot.Btn { return mk.Data(text, a.btnRuleLimit.Unique, data) }
+	mk.Inline(
+		mk.Row(limit("♾ Без конца", "none"), limit("📅 До даты", "until")),
+		mk.Row(limit("5 раз", "5"), limit("10 раз", "10"), limit("20 раз", "20")),
+	)
+	return mk
+}
+
+// askRule shows the rule presets, editing the message of a callback.
+func (a *BotApp) askRule(c telebot.Context, s *fsm.Session[AddState]) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	s.State = stateRule
+	if err := a.flow.Set(c.Sender().ID, s); err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+	}
+	_ = c.Respond()
+	return c.Edit(chooseRuleTitle, a.ruleMarkup(timeutil.StartOfDay(a.userNow(u))))
+}
+
+// setRule makes the draft repeat by r from today; the end is asked next
+// unless the rule already has one.
+func (a *BotApp) setRule(c telebot.Context, s *fsm.Session[AddState], r timeutil.Recurrence) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Ошибка пользователя")
+	}
+	today := timeutil.StartOfDay(a.userNow(u))
+	if _, ok := r.Next(today, today); !ok {
+		return c.Send("По этому правилу нет ни одного дня. " + chooseRuleTitle)
+	}
+	s.Data.RRule, s.Data.RRuleStart = r.String(), today.Format(timeutil.DateLayout)
+	s.Data.Date = ""
+	s.Data.DaysMask = r.Weekdays(today)
+	if r.Count > 0 || !r.Until.IsZero() {
+		return a.finishRule(c, s)
+	}
+	s.State = stateRuleLimit
+	if err := a.flow.Set(c.Sender().ID, s); err != nil {
+		return c.Send("Ошибка")
+	}
+	if c.Callback() != nil {
+		_ = c.Respond()
+		return c.Edit(r.Describe(today)+"\n"+chooseLimitTitle, a.limitMarkup())
+	}
+	return c.Send(r.Describe(today)+"\n"+chooseLimitTitle, a.limitMarkup())
+}
+
+// limitRule adds COUNT or UNTIL to the draft's rule and finishes it.
+func (a *BotApp) limitRule(c telebot.Context, s *fsm.Session[AddState], count int, until time.Time) error {
+	r, err := timeutil.ParseRecurrence(s.Data.RRule)
+	if err != nil {
+		return c.Send("Ошибка правила повтора")
+	}
+	r.Count, r.Until = count, until
+	if !until.IsZero() {
+		start, _ := time.Parse(timeutil.DateLayout, s.Data.RRuleStart)
+		if _, ok := r.Next(start, start); !ok {
+			return c.Send("До этой даты нет ни одного повтора. " + chooseLimitTitle)
+		}
+	}
+	s.Data.RRule = r.String()
+	return a.finishRule(c, s)
+}
+
+// finishRule saves an edited task, or moves the add wizard to reminders.
+func (a *BotApp) finishRule(c telebot.Context, s *fsm.Session[AddState]) error {
+	if s.Data.EditID == 0 {
+		return a.askReminders(c, s, stateAddRemind)
+	}
+	s.State = fsm.Done
+	if err := a.flow.Set(c.Sender().ID, s); err != nil {
+		return c.Send("Ошибка")
+	}
+	if c.Callback() != nil {
+		_ = c.Respond()
+	}
+	return a.finishEdit(c, s.Data)
+}
+
+func (a *BotApp) cbRulePreset(c telebot.Context) error {
+	s, err := a.flow.Get(c.Sender().ID)
+	if err != nil || s == nil || s.State != stateRule {
+		return c.Respond(&telebot.CallbackResponse{Text: "Нет активного добавления"})
+	}
+	r, err := timeutil.ParseRecurrence(c.Callback().Data)
+	if err != nil {
+		return c.Respond()
+	}
+	return a.setRule(c, s, r)
+}
+
+func (a *BotApp) cbRuleLimit(c telebot.Context) error {
+	s, err := a.flow.Get(c.Sender().ID)
+	if err != nil || s == nil || s.State != stateRuleLimit {
+		return c.Respond(&telebot.CallbackResponse{Text: "Нет активного добавления"})
+	}
+	switch data := c.Callback().Data; data {
+	case "none":
+		return a.finishRule(c, s)
+	case "until":
+		u, err := a.St.GetUserByTGID(c.Sender().ID)
+		if err != nil {
+			return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+		}
+		s.State = stateRuleUntil
+		if err := a.flow.Set(c.Sender().ID, s); err != nil {
+			return c.Respond(&telebot.CallbackResponse{Text: "Ошибка"})
+		}
+		today := timeutil.StartOfDay(a.userNow(u))
+		_ = c.Respond()
+		return c.Edit("До какой даты повторять?", a.dateMarkup(today, today, AddState{}))
+	default:
+		n, err := strconv.Atoi(data)
+		if err != nil {
+			return c.Respond()
+		}
+		return a.limitRule(c, s, n, time.Time{})
+	}
+}
+
+// onRuleText takes a typed RRULE instead of a preset.
+func (a *BotApp) onRuleText(c telebot.Context, s *fsm.Session[AddState]) error {
+	r, err := timeutil.ParseRecurrence(c.Text())
+	if err != nil {
+		return c.Send("Не понял правило (" + err.Error() + "). " + chooseRuleTitle)
+	}
+	return a.setRule(c, s, r)
+}
+
+// onLimitText takes "10", "10 раз" or an end date.
+func (a *BotApp) onLimitText(c telebot.Context, s *fsm.Session[AddState]) error {
+	fields := strings.Fields(c.Text())
+	if len(fields) == 2 && strings.HasPrefix(fields[1], "раз") {
+		fields = fields[:1]
+	}
+	if n, err := strconv.Atoi(strings.Join(fields, " ")); err == nil {
+		if n < 1 || n > 1000 {
+			return c.Send("Число повторов — от 1 до 1000.")
+		}
+		return a.limitRule(c, s, n, time.Time{})
+	}
+	return a.onUntilText(c, s)
+}
+
+// onUntilText takes a typed end date of the rule.
+func (a *BotApp) onUntilText(c telebot.Context, s *fsm.Session[AddState]) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Ошибка пользователя")
+	}
+	now := a.userNow(u)
+	day, ok := parseDay(c.Text(), now)
+	if !ok || day.Before(timeutil.StartOfDay(now)) {
+		return c.Send("Не понял дату окончания. " + chooseLimitTitle)
+	}
+	return a.limitRule(c, s, 0, day)
+}
+
+// clearRule drops the draft's recurrence rule, e.g. when weekdays or a date
+// are chosen instead.
+func (st *AddState) clearRule() { st.RRule, st.RRuleStart = "", "" }
+
+// describeRule is the "Повтор: …" text of a task with a rule.
+func describeRule(rule, start string) (string, bool) {
+	r, err := timeutil.ParseRecurrence(rule)
+	if err != nil {
+		return "", false
+	}
+	from, err := time.Parse(timeutil.DateLayout, start)
+	if err != nil {
+		return "", false
+	}
+	return r.Describe(from), true
+}
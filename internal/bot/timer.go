@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/telebot.v3"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/scheduler"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+)
+
+const timerUsage = "Секундомер:\n/timer start <название или задача> — запустить\n/timer stop — остановить\n/timer status — что сейчас идёт"
+
+// formatHM renders a duration in seconds as "HHч MMм".
+func formatHM(sec int64) string {
+	return fmt.Sprintf("%02dч %02dм", sec/3600, (sec%3600)/60)
+}
+
+// handleTimer — /timer [start <название>|stop|status]
+func (a *BotApp) handleTimer(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Send("Сначала /start")
+	}
+	cmd, rest, _ := strings.Cut(strings.TrimSpace(c.Message().Payload), " ")
+	switch cmd {
+	case "", "status":
+		return a.sendTimerStatus(c, u)
+	case "start":
+		title := strings.TrimSpace(rest)
+		if title == "" {
+			return a.sendTimerStatus(c, u)
+		}
+		return a.startTimer(c, u, title)
+	case "stop":
+		return a.stopTimer(c, u)
+	}
+	return c.Send(timerUsage)
+}
+
+// sendTimerStatus shows open runs, or task buttons to start a timer.
+func (a *BotApp) sendTimerStatus(c telebot.Context, u store.User) error {
+	runs, err := a.St.ActiveRuns(u.ID)
+	if err != nil {
+		return c.Send("Ошибка")
+	}
+	if len(runs) > 0 {
+		var b strings.Builder
+		b.WriteString("Сейчас идёт:\n")
+		hasTimer := false
+		for _, r := range runs {
+			kind := "по расписанию"
+			if r.Source == store.RunTimer {
+				kind, hasTimer = "таймер", true
+			}
+			fmt.Fprintf(&b, "• %s — %s (%s)\n", r.DisplayTitle, formatHM(a.St.Clock.Now().Unix()-r.StartTs), kind)
+		}
+		if !hasTimer {
+			return c.Send(b.String())
+		}
+		mk := &telebot.ReplyMarkup{}
+		mk.Inline(mk.Row(mk.Data("⏹ Остановить таймер", a.btnTimerStop.Unique, "go")))
+		return c.Send(b.String(), mk)
+	}
+	tasks, err := a.St.ListTasks(u.ID)
+	if err != nil {
+		return c.Send("Ошибка")
+	}
+	mk := &telebot.ReplyMarkup{}
+	rows := []telebot.Row{}
+	for _, t := range tasks {
+		rows = append(rows, mk.Row(mk.Data("▶️ "+t.Title, a.btnTimerTask.Unique, strconv.FormatInt(t.ID, 10))))
+	}
+	mk.Inline(rows...)
+	return c.Send("Таймер не запущен. Выберите задачу или отправьте /timer start <название> для внеплановой работы.", mk)
+}
+
+func (a *BotApp) startTimer(c telebot.Context, u store.User, title string) error {
+	var taskID *int64
+	tasks, err := a.St.ListTasks(u.ID)
+	if err != nil {
+		return c.Send("Ошибка")
+	}
+	for _, t := range tasks {
+		if strings.EqualFold(t.Title, title) {
+			id := t.ID
+			taskID, title = &id, t.Title
+			break
+		}
+	}
+	return a.startTimerFor(c, u, taskID, title)
+}
+
+func (a *BotApp) startTimerFor(c telebot.Context, u store.User, taskID *int64, title string) error {
+	_, err := a.Sch.StartTimer(u, taskID, title)
+	if errors.Is(err, store.ErrRunOpen) {
+		return c.Send("Уже идёт другая задача. Сначала /timer stop или дождитесь финиша.")
+	}
+	if err != nil {
+		return c.Send("Не удалось запустить таймер")
+	}
+	kind := "внеплановая работа"
+	if taskID != nil {
+		kind = "задача из списка"
+	}
+	return c.Send(fmt.Sprintf("⏱ Таймер запущен: %s (%s).\nОстановить: /timer stop", title, kind))
+}
+
+func (a *BotApp) stopTimer(c telebot.Context, u store.User) error {
+	r, end, err := a.Sch.StopTimer(u)
+	if errors.Is(err, scheduler.ErrNoTimer) {
+		return c.Send("Таймер не запущен.")
+	}
+	if err != nil {
+		return c.Send("Ошибка")
+	}
+	return c.Send(fmt.Sprintf("⏹ Таймер остановлен: %s — %s", r.DisplayTitle, formatHM(end.Unix()-r.StartTs)))
+}
+
+func (a *BotApp) cbTimerTask(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	var taskID int64
+	fmt.Sscanf(c.Callback().Data, "%d", &taskID)
+	t, err := a.St.GetTask(u.ID, taskID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Задача не найдена"})
+	}
+	_ = c.Respond()
+	return a.startTimerFor(c, u, &t.ID, t.Title)
+}
+
+func (a *BotApp) cbTimerStop(c telebot.Context) error {
+	u, err := a.St.GetUserByTGID(c.Sender().ID)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "Ошибка пользователя"})
+	}
+	_ = c.Respond()
+	return a.stopTimer(c, u)
+}
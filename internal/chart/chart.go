@@ -0,0 +1,266 @@
+// Package chart draws report charts as PNG images using only the standard
+// library and a built-in bitmap font.
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"time"
+)
+
+const (
+	// scale enlarges the 5x7 font; a character cell is charW x charH pixels.
+	scale = 2
+	charW = (glyphW + 1) * scale
+	charH = (glyphH + 2) * scale
+
+	width  = 800
+	margin = 20
+)
+
+var (
+	background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	ink        = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	muted      = color.RGBA{0x99, 0x99, 0x99, 0xff}
+	grid       = color.RGBA{0xe4, 0xe4, 0xe4, 0xff}
+
+	// palette colours series (tasks) in order.
+	palette = []color.RGBA{
+		{0x4e, 0x79, 0xa7, 0xff}, {0xf2, 0x8e, 0x2b, 0xff}, {0x59, 0xa1, 0x4f, 0xff},
+		{0xe1, 0x57, 0x59, 0xff}, {0x76, 0xb7, 0xb2, 0xff}, {0xed, 0xc9, 0x48, 0xff},
+		{0xb0, 0x7a, 0xa1, 0xff}, {0xff, 0x9d, 0xa7, 0xff}, {0x9c, 0x75, 0x5f, 0xff},
+		{0xba, 0xb0, 0xac, 0xff},
+	}
+)
+
+// Color returns the colour of series i.
+func Color(i int) color.RGBA { return palette[i%len(palette)] }
+
+type canvas struct {
+	img *image.RGBA
+}
+
+func newCanvas(w, h int) *canvas {
+	c := &canvas{img: image.NewRGBA(image.Rect(0, 0, w, h))}
+	c.rect(0, 0, w, h, background)
+	return c
+}
+
+func (c *canvas) rect(x0, y0, x1, y1 int, col color.Color) {
+	draw.Draw(c.img, image.Rect(x0, y0, x1, y1), image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+// text draws s with its top-left corner at (x, y) and returns the width.
+func (c *canvas) text(x, y int, s string, col color.Color) int {
+	cx := x
+	for _, r := range s {
+		g := glyph(r)
+		for gy, row := range g {
+			for gx, px := range row {
+				if px == '#' {
+					c.rect(cx+gx*scale, y+gy*scale, cx+(gx+1)*scale, y+(gy+1)*scale, col)
+				}
+			}
+		}
+		cx += charW
+	}
+	return cx - x
+}
+
+func textWidth(s string) int { return len([]rune(s)) * charW }
+
+// clip shortens s to at most n runes.
+func clip(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func (c *canvas) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// hm formats seconds as H:MM.
+func hm(sec int64) string {
+	return fmt.Sprintf("%d:%02d", sec/3600, (sec%3600)/60)
+}
+
+// Bar is one task in a bar chart.
+type Bar struct {
+	Label   string
+	Seconds int64
+}
+
+// maxBars limits the bar chart; the rest are summed into one bar.
+const maxBars = 12
+
+// Bars draws a horizontal bar per task with its total time.
+func Bars(title string, bars []Bar) ([]byte, error) {
+	if len(bars) > maxBars {
+		rest := Bar{Label: "Прочее"}
+		for _, b := range bars[maxBars-1:] {
+			rest.Seconds += b.Seconds
+		}
+		bars = append(bars[:maxBars-1:maxBars-1], rest)
+	}
+	const (
+		labelChars = 18
+		rowH       = 36
+		barH       = 22
+		top        = 60
+	)
+	h := top + len(bars)*rowH + margin
+	c := newCanvas(width, h)
+	c.text(margin, margin, title, ink)
+
+	x0 := margin + labelChars*charW + 10
+	x1 := width - margin - textWidth("000:00") - 10
+	var max int64 = 1
+	for _, b := range bars {
+		if b.Seconds > max {
+			max = b.Seconds
+		}
+	}
+	for i, b := range bars {
+		y := top + i*rowH
+		c.text(margin, y+(barH-glyphH*scale)/2, clip(b.Label, labelChars), ink)
+		w := int(int64(x1-x0) * b.Seconds / max)
+		c.rect(x0, y, x0+w, y+barH, Color(i))
+		c.text(x0+w+8, y+(barH-glyphH*scale)/2, hm(b.Seconds), muted)
+	}
+	return c.encode()
+}
+
+// Segment is a stretch of work within a day, as offsets from midnight.
+type Segment struct {
+	Series   int
+	From, To time.Duration
+}
+
+// Day is one row of a timeline.
+type Day struct {
+	Label    string
+	Segments []Segment
+}
+
+// Timeline draws one 24-hour strip per day with the work segments coloured
+// by series, and a legend of series names below.
+func Timeline(title string, series []string, days []Day) ([]byte, error) {
+	const (
+		labelChars = 8
+		rowH       = 34
+		barH       = 22
+		top        = 84
+		legendRowH = 26
+	)
+	legendRows := (len(series) + 1) / 2
+	h := top + len(days)*rowH + 16 + legendRows*legendRowH + margin
+	c := newCanvas(width, h)
+	c.text(margin, margin, title, ink)
+
+	x0 := margin + labelChars*charW + 10
+	x1 := width - margin - 10
+	span := x1 - x0
+	xAt := func(d time.Duration) int { return x0 + int(int64(span)*int64(d)/int64(24*time.Hour)) }
+	bottom := top + len(days)*rowH - (rowH - barH)
+	for hr := 0; hr <= 24; hr += 3 {
+		x := xAt(time.Duration(hr) * time.Hour)
+		c.rect(x, top-6, x+1, bottom, grid)
+		label := fmt.Sprint(hr)
+		c.text(x-textWidth(label)/2, top-6-charH, label, muted)
+	}
+	for i, d := range days {
+		y := top + i*rowH
+		c.text(margin, y+(barH-glyphH*scale)/2, d.Label, ink)
+		c.rect(x0, y+barH/2, x1, y+barH/2+1, grid)
+		for _, s := range d.Segments {
+			l, r := xAt(s.From), xAt(s.To)
+			if r == l {
+				r = l + 1
+			}
+			c.rect(l, y, r, y+barH, Color(s.Series))
+		}
+	}
+	ly := bottom + 16
+	colW := (width - 2*margin) / 2
+	for i, name := range series {
+		x := margin + (i%2)*colW
+		y := ly + (i/2)*legendRowH
+		c.rect(x, y, x+charH, y+charH-4, Color(i))
+		c.text(x+charH+8, y, clip(name, (colW-charH-16)/charW), ink)
+	}
+	return c.encode()
+}
+
+// Heatmap draws a calendar (weeks by rows, Monday first) of consecutive
+// days, shading each day by its share of the busiest day.
+func Heatmap(title string, first time.Time, seconds []int64) ([]byte, error) {
+	const (
+		cellW = 104
+		cellH = 56
+		gap   = 4
+		top   = 84
+	)
+	offset := (int(first.Weekday()) + 6) % 7
+	weeks := (offset + len(seconds) + 6) / 7
+	h := top + weeks*(cellH+gap) + 40 + margin
+	c := newCanvas(width, h)
+	c.text(margin, margin, title, ink)
+
+	var max int64 = 1
+	for _, s := range seconds {
+		if s > max {
+			max = s
+		}
+	}
+	x0 := (width - 7*(cellW+gap) + gap) / 2
+	for i, name := range []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"} {
+		c.text(x0+i*(cellW+gap)+(cellW-textWidth(name))/2, top-charH-4, name, muted)
+	}
+	for i, s := range seconds {
+		cell := offset + i
+		x := x0 + (cell%7)*(cellW+gap)
+		y := top + (cell/7)*(cellH+gap)
+		c.rect(x, y, x+cellW, y+cellH, shade(float64(s)/float64(max), s > 0))
+		txt := ink
+		if s*2 > max {
+			txt = background
+		}
+		c.text(x+6, y+6, fmt.Sprint(first.AddDate(0, 0, i).Day()), txt)
+		if s > 0 {
+			label := hm(s)
+			c.text(x+cellW-6-textWidth(label), y+cellH-6-glyphH*scale, label, txt)
+		}
+	}
+	// scale legend
+	ly := top + weeks*(cellH+gap) + 12
+	lx := x0
+	c.text(lx, ly, "0", muted)
+	lx += charW + 6
+	for i := 0; i <= 4; i++ {
+		c.rect(lx+i*28, ly, lx+(i+1)*28-2, ly+glyphH*scale, shade(float64(i)/4, i > 0))
+	}
+	c.text(lx+5*28+6, ly, hm(max), muted)
+	return c.encode()
+}
+
+// shade blends from light grey (no work) through light to dark blue.
+func shade(v float64, any bool) color.RGBA {
+	if !any {
+		return color.RGBA{0xf0, 0xf0, 0xf0, 0xff}
+	}
+	lo := color.RGBA{0xd6, 0xe6, 0xf5, 0xff}
+	hi := color.RGBA{0x08, 0x45, 0x94, 0xff}
+	mix := func(a, b uint8) uint8 { return uint8(float64(a) + (float64(b)-float64(a))*v) }
+	return color.RGBA{mix(lo.R, hi.R), mix(lo.G, hi.G), mix(lo.B, hi.B), 0xff}
+}
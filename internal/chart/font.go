@@ -0,0 +1,105 @@
+package chart
+
+import "unicode"
+
+// A tiny built-in 5x7 bitmap font: digits, Latin and Cyrillic capitals and
+// basic punctuation. Text is drawn upper-cased; unknown runes become '?'.
+const (
+	glyphW = 5
+	glyphH = 7
+)
+
+var glyphs = map[rune][glyphH]string{
+	' ': {".....", ".....", ".....", ".....", ".....", ".....", "....."},
+	'0': {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	'A': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D': {"###..", "#..#.", "#...#", "#...#", "#...#", "#..#.", "###.."},
+	'E': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F': {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G': {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".####"},
+	'H': {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'I': {".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'J': {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K': {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L': {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M': {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N': {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'O': {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'P': {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q': {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R': {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S': {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U': {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V': {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W': {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X': {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y': {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z': {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+	'Б': {"#####", "#....", "#....", "####.", "#...#", "#...#", "####."},
+	'Г': {"#####", "#....", "#....", "#....", "#....", "#....", "#...."},
+	'Д': {"..##.", ".#.#.", ".#.#.", ".#.#.", ".#.#.", "#####", "#...#"},
+	'Ж': {"#.#.#", "#.#.#", ".###.", "..#..", ".###.", "#.#.#", "#.#.#"},
+	'З': {".###.", "#...#", "....#", "..##.", "....#", "#...#", ".###."},
+	'И': {"#...#", "#...#", "#..##", "#.#.#", "##..#", "#...#", "#...#"},
+	'Й': {".#.#.", "..#..", "#...#", "#..##", "#.#.#", "##..#", "#...#"},
+	'Л': {"..###", ".#..#", ".#..#", ".#..#", ".#..#", ".#..#", "#...#"},
+	'П': {"#####", "#...#", "#...#", "#...#", "#...#", "#...#", "#...#"},
+	'У': {"#...#", "#...#", "#...#", ".####", "....#", "#...#", ".###."},
+	'Ф': {"..#..", ".###.", "#.#.#", "#.#.#", "#.#.#", ".###.", "..#.."},
+	'Ц': {"#..#.", "#..#.", "#..#.", "#..#.", "#..#.", "#####", "....#"},
+	'Ч': {"#...#", "#...#", "#...#", ".####", "....#", "....#", "....#"},
+	'Ш': {"#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", "#.#.#", "#####"},
+	'Щ': {"#.#.#", "#.#.#", "#.#.#", "#.#.#", "#.#.#", "#####", "....#"},
+	'Ъ': {"##...", ".#...", ".#...", ".###.", ".#..#", ".#..#", ".###."},
+	'Ы': {"#...#", "#...#", "#...#", "###.#", "#.#.#", "#.#.#", "###.#"},
+	'Ь': {"#....", "#....", "#....", "####.", "#...#", "#...#", "####."},
+	'Э': {".###.", "#...#", "....#", ".####", "....#", "#...#", ".###."},
+	'Ю': {"#..#.", "#.#.#", "#.#.#", "###.#", "#.#.#", "#.#.#", "#..#."},
+	'Я': {".####", "#...#", "#...#", ".####", "..#.#", ".#..#", "#...#"},
+	'Ё': {".#.#.", ".....", "#####", "#....", "####.", "#....", "#####"},
+	'.': {".....", ".....", ".....", ".....", ".....", ".....", "..#.."},
+	',': {".....", ".....", ".....", ".....", ".....", "..#..", ".#..."},
+	':': {".....", "..#..", ".....", ".....", ".....", "..#..", "....."},
+	'-': {".....", ".....", ".....", ".###.", ".....", ".....", "....."},
+	'—': {".....", ".....", ".....", "#####", ".....", ".....", "....."},
+	'+': {".....", "..#..", "..#..", "#####", "..#..", "..#..", "....."},
+	'/': {"....#", "....#", "...#.", "..#..", ".#...", "#....", "#...."},
+	'%': {"##..#", "##..#", "...#.", "..#..", ".#...", "#..##", "#..##"},
+	'(': {"...#.", "..#..", ".#...", ".#...", ".#...", "..#..", "...#."},
+	')': {".#...", "..#..", "...#.", "...#.", "...#.", "..#..", ".#..."},
+	'!': {"..#..", "..#..", "..#..", "..#..", "..#..", ".....", "..#.."},
+	'?': {".###.", "#...#", "....#", "...#.", "..#..", ".....", "..#.."},
+	'"': {".#.#.", ".#.#.", ".....", ".....", ".....", ".....", "....."},
+	'_': {".....", ".....", ".....", ".....", ".....", ".....", "#####"},
+	'·': {".....", ".....", ".....", "..#..", ".....", ".....", "....."},
+}
+
+// aliases maps runes drawn with another rune's glyph.
+var aliases = map[rune]rune{
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O',
+	'Р': 'P', 'С': 'C', 'Т': 'T', 'Х': 'X', 'І': 'I', 'Ї': 'I',
+	'–': '-', '«': '"', '»': '"', '\'': '"', '…': '.',
+}
+
+func glyph(r rune) [glyphH]string {
+	r = unicode.ToUpper(r)
+	if a, ok := aliases[r]; ok {
+		r = a
+	}
+	if g, ok := glyphs[r]; ok {
+		return g
+	}
+	return glyphs['?']
+}
@@ -4,12 +4,32 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	BotToken    string
 	DatabaseURL string
 	DefaultTZ   string
+	// NotifyMissed enables messages about start/finish events missed while
+	// the bot was down (NOTIFY_MISSED, on by default).
+	NotifyMissed bool
+	// AdminIDs are Telegram user IDs allowed to run admin commands such as
+	// /migrate (ADMIN_IDS, comma-separated).
+	AdminIDs []int64
+	// TimerMax is the longest a manual /timer run may last before it is
+	// stopped automatically (TIMER_MAX, Go duration, default 8h).
+	TimerMax time.Duration
+	// SMTPAddr and SMTPFrom enable the email notification channel: a mail
+	// server without authentication, such as a local relay (SMTP_ADDR,
+	// host:port; SMTP_FROM, the sender address).
+	SMTPAddr string
+	SMTPFrom string
+	// NotifyLog is the file the log notification channel appends JSON lines
+	// to (NOTIFY_LOG); empty disables the channel.
+	NotifyLog string
 }
 
 func Load() Config {
@@ -17,6 +37,34 @@ func Load() Config {
 		BotToken:    os.Getenv("BOT_TOKEN"),
 		DatabaseURL: os.Getenv("DATABASE_URL"),
 		DefaultTZ:   os.Getenv("DEFAULT_TZ"),
+		SMTPAddr:    os.Getenv("SMTP_ADDR"),
+		SMTPFrom:    os.Getenv("SMTP_FROM"),
+		NotifyLog:   os.Getenv("NOTIFY_LOG"),
+	}
+	switch os.Getenv("NOTIFY_MISSED") {
+	case "0", "false", "no":
+		cfg.NotifyMissed = false
+	default:
+		cfg.NotifyMissed = true
+	}
+	for _, f := range strings.Split(os.Getenv("ADMIN_IDS"), ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			log.Fatalf("bad ADMIN_IDS entry %q: %v", f, err)
+		}
+		cfg.AdminIDs = append(cfg.AdminIDs, id)
+	}
+	cfg.TimerMax = 8 * time.Hour
+	if v := os.Getenv("TIMER_MAX"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("bad TIMER_MAX %q", v)
+		}
+		cfg.TimerMax = d
 	}
 	if cfg.BotToken == "" {
 		log.Fatal("BOT_TOKEN is not set")
@@ -27,6 +75,9 @@ func Load() Config {
 	if cfg.DefaultTZ == "" {
 		cfg.DefaultTZ = "Europe/Kyiv"
 	}
+	if cfg.SMTPAddr != "" && cfg.SMTPFrom == "" {
+		log.Fatal("SMTP_FROM is required with SMTP_ADDR")
+	}
 	dir := filepath.Dir(cfg.DatabaseURL)
 	if dir != "." && dir != "" {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
@@ -0,0 +1,194 @@
+// Package export writes a user's tasks and tracked time in formats other
+// tools can ingest. All timestamps are given in the user's time zone.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// Data is what gets exported: all tasks of the user and the runs that
+// overlap [From, To). A zero From means "all time".
+type Data struct {
+	Loc   *time.Location
+	From  time.Time
+	To    time.Time
+	Tasks []store.Task
+	Runs  []store.TaskRun
+}
+
+// Task is the exported form of a task.
+type Task struct {
+	ID         int64   `json:"id"`
+	Title      string  `json:"title"`
+	Start      string  `json:"start"`
+	End        string  `json:"end"`
+	Days       string  `json:"days"`
+	Date       *string `json:"date"`
+	RRule      *string `json:"rrule"`
+	Enabled    bool    `json:"enabled"`
+	Archived   bool    `json:"archived"`
+	CreatedAt  *string `json:"created_at"`
+	ArchivedAt *string `json:"archived_at"`
+}
+
+// Run is the exported form of a task run. End is nil for a run in progress.
+type Run struct {
+	ID       int64   `json:"id"`
+	TaskID   *int64  `json:"task_id"`
+	Title    string  `json:"title"`
+	Source   string  `json:"source"`
+	Date     string  `json:"date"`
+	Start    string  `json:"start"`
+	End      *string `json:"end"`
+	Duration int64   `json:"duration_sec"`
+}
+
+// dayCodes are weekday codes in days_mask bit order (Monday first).
+var dayCodes = []struct {
+	bit  int
+	code string
+}{
+	{timeutil.BitMon, "MO"}, {timeutil.BitTue, "TU"}, {timeutil.BitWed, "WE"}, {timeutil.BitThu, "TH"},
+	{timeutil.BitFri, "FR"}, {timeutil.BitSat, "SA"}, {timeutil.BitSun, "SU"},
+}
+
+// DayCodes lists the iCalendar weekday codes (MO, TU, ...) set in mask.
+func DayCodes(mask int) []string {
+	var out []string
+	for _, d := range dayCodes {
+		if mask&d.bit != 0 {
+			out = append(out, d.code)
+		}
+	}
+	return out
+}
+
+func (d Data) stamp(unix int64) string {
+	return time.Unix(unix, 0).In(d.Loc).Format(time.RFC3339)
+}
+
+func (d Data) optStamp(unix *int64) *string {
+	if unix == nil {
+		return nil
+	}
+	s := d.stamp(*unix)
+	return &s
+}
+
+// ExportTasks converts the tasks.
+func (d Data) ExportTasks() []Task {
+	out := make([]Task, 0, len(d.Tasks))
+	for _, t := range d.Tasks {
+		out = append(out, Task{
+			ID: t.ID, Title: t.Title,
+			Start:      fmt.Sprintf("%02d:%02d", t.StartH, t.StartM),
+			End:        fmt.Sprintf("%02d:%02d", t.EndH, t.EndM),
+			Days:       strings.Join(DayCodes(t.DaysMask), ","),
+			Date:       t.OnDate,
+			RRule:      t.RRule,
+			Enabled:    t.Enabled,
+			Archived:   t.ArchivedAt != nil,
+			CreatedAt:  d.optStamp(t.CreatedAt),
+			ArchivedAt: d.optStamp(t.ArchivedAt),
+		})
+	}
+	return out
+}
+
+// ExportRuns converts the runs, in order; free-form timer runs keep their own
+// title.
+func (d Data) ExportRuns(now time.Time) []Run {
+	titles := make(map[int64]string, len(d.Tasks))
+	for _, t := range d.Tasks {
+		titles[t.ID] = t.Title
+	}
+	out := make([]Run, 0, len(d.Runs))
+	for _, r := range d.Runs {
+		run := Run{
+			ID: r.ID, TaskID: r.TaskID, Source: r.Source,
+			Date:  time.Unix(r.StartTs, 0).In(d.Loc).Format(timeutil.DateLayout),
+			Start: d.stamp(r.StartTs),
+			End:   d.optStamp(r.EndTs),
+		}
+		switch {
+		case r.TaskID != nil:
+			run.Title = titles[*r.TaskID]
+		case r.Title != nil:
+			run.Title = *r.Title
+		}
+		end := now.Unix()
+		if r.EndTs != nil {
+			end = *r.EndTs
+		}
+		run.Duration = end - r.StartTs
+		out = append(out, run)
+	}
+	return out
+}
+
+// JSON writes the tasks and runs as one JSON document.
+func JSON(w io.Writer, d Data, now time.Time) error {
+	doc := struct {
+		TZ    string  `json:"tz"`
+		From  *string `json:"from,omitempty"`
+		To    *string `json:"to,omitempty"`
+		Tasks []Task  `json:"tasks"`
+		Runs  []Run   `json:"runs"`
+	}{TZ: d.Loc.String(), Tasks: d.ExportTasks(), Runs: d.ExportRuns(now)}
+	if !d.From.IsZero() {
+		from, to := d.From.In(d.Loc).Format(time.RFC3339), d.To.In(d.Loc).Format(time.RFC3339)
+		doc.From, doc.To = &from, &to
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func optString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// TasksCSV writes the tasks as CSV with a header row.
+func TasksCSV(w io.Writer, d Data) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "title", "start", "end", "days", "date", "rrule", "enabled", "archived", "created_at", "archived_at"})
+	for _, t := range d.ExportTasks() {
+		_ = cw.Write([]string{
+			strconv.FormatInt(t.ID, 10), t.Title, t.Start, t.End, t.Days, optString(t.Date), optString(t.RRule),
+			strconv.FormatBool(t.Enabled), strconv.FormatBool(t.Archived),
+			optString(t.CreatedAt), optString(t.ArchivedAt),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// RunsCSV writes the runs as CSV with a header row.
+func RunsCSV(w io.Writer, d Data, now time.Time) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "task_id", "title", "source", "date", "start", "end", "duration_sec"})
+	for _, r := range d.ExportRuns(now) {
+		taskID := ""
+		if r.TaskID != nil {
+			taskID = strconv.FormatInt(*r.TaskID, 10)
+		}
+		_ = cw.Write([]string{
+			strconv.FormatInt(r.ID, 10), taskID, r.Title, r.Source, r.Date,
+			r.Start, optString(r.End), strconv.FormatInt(r.Duration, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
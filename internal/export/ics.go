@@ -0,0 +1,218 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// uidDomain makes event UIDs globally unique (RFC 5545 3.8.4.7).
+const uidDomain = "telegram-schedule-bot"
+
+const (
+	icsLocal = "20060102T150405"
+	icsUTC   = "20060102T150405Z"
+)
+
+// icsWriter emits content lines with CRLF endings, folded at 75 octets.
+type icsWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (iw *icsWriter) line(format string, args ...any) {
+	if iw.err != nil {
+		return
+	}
+	s := fmt.Sprintf(format, args...)
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+	_, iw.err = io.WriteString(iw.w, b.String())
+}
+
+// escapeText escapes a TEXT value (RFC 5545 3.3.11).
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "").Replace(s)
+}
+
+// ICS writes an RFC 5545 calendar with one weekly recurring event per
+// enabled task (a single event for a one-off task), in the user's time zone. With runs set, every finished run
+// is added as a separate event.
+func ICS(w io.Writer, d Data, now time.Time, runs bool) error {
+	iw := &icsWriter{w: w}
+	tz := d.Loc.String()
+	stamp := now.UTC().Format(icsUTC)
+
+	iw.line("BEGIN:VCALENDAR")
+	iw.line("VERSION:2.0")
+	iw.line("PRODID:-//okpulse//Telegram Schedule Bot//RU")
+	iw.line("CALSCALE:GREGORIAN")
+	iw.line("METHOD:PUBLISH")
+	iw.line("X-WR-TIMEZONE:%s", tz)
+	writeTimezone(iw, d.Loc, now)
+
+	for _, t := range d.Tasks {
+		days := DayCodes(t.DaysMask)
+		if !t.Enabled || t.ArchivedAt != nil || len(days) == 0 {
+			continue
+		}
+		// the first occurrence on or after the task's creation; a one-off
+		// task has just its date, a rule task starts at its first occurrence
+		day := now.In(d.Loc)
+		if t.CreatedAt != nil {
+			day = time.Unix(*t.CreatedAt, 0).In(d.Loc)
+		}
+		rule, ruleStart, hasRule := t.Recurrence()
+		switch {
+		case t.OnDate != nil:
+			var err error
+			if day, err = time.ParseInLocation(timeutil.DateLayout, *t.OnDate, d.Loc); err != nil {
+				continue
+			}
+		case hasRule:
+			first, ok := rule.Next(ruleStart, ruleStart)
+			if !ok {
+				continue
+			}
+			day = timeutil.At(first.Year(), first.Month(), first.Day(), 0, 0, d.Loc)
+		}
+		for !t.RunsOn(day) {
+			day = timeutil.AddDays(day, 1)
+		}
+		endOffset := 0
+		if t.Overnight() {
+			endOffset = 1
+		}
+		start := timeutil.DateTimeOn(day, t.StartH, t.StartM, 0)
+		end := timeutil.DateTimeOn(day, t.EndH, t.EndM, endOffset)
+
+		iw.line("BEGIN:VEVENT")
+		iw.line("UID:task-%d@%s", t.ID, uidDomain)
+		iw.line("DTSTAMP:%s", stamp)
+		iw.line("DTSTART;TZID=%s:%s", tz, start.Format(icsLocal))
+		iw.line("DTEND;TZID=%s:%s", tz, end.Format(icsLocal))
+		switch {
+		case hasRule:
+			iw.line("RRULE:%s", icsRule(rule, d.Loc))
+		case t.OnDate == nil:
+			iw.line("RRULE:FREQ=WEEKLY;BYDAY=%s", strings.Join(days, ","))
+		}
+		iw.line("SUMMARY:%s", escapeText(t.Title))
+		iw.line("END:VEVENT")
+	}
+
+	if runs {
+		for i, r := range d.ExportRuns(now) {
+			raw := d.Runs[i]
+			if raw.EndTs == nil {
+				continue
+			}
+			iw.line("BEGIN:VEVENT")
+			iw.line("UID:run-%d@%s", r.ID, uidDomain)
+			iw.line("DTSTAMP:%s", stamp)
+			iw.line("DTSTART:%s", time.Unix(raw.StartTs, 0).UTC().Format(icsUTC))
+			iw.line("DTEND:%s", time.Unix(*raw.EndTs, 0).UTC().Format(icsUTC))
+			iw.line("SUMMARY:%s", escapeText("✔ "+r.Title))
+			iw.line("TRANSP:TRANSPARENT")
+			iw.line("END:VEVENT")
+		}
+	}
+	iw.line("END:VCALENDAR")
+	return iw.err
+}
+
+// icsRule is the RRULE value of r. UNTIL must be a date-time like DTSTART,
+// so the end date becomes its last local second in UTC.
+func icsRule(r timeutil.Recurrence, loc *time.Location) string {
+	if r.Until.IsZero() {
+		return r.String()
+	}
+	until := time.Date(r.Until.Year(), r.Until.Month(), r.Until.Day(), 23, 59, 59, 0, loc)
+	r.Until = time.Time{}
+	return r.String() + ";UNTIL=" + until.UTC().Format(icsUTC)
+}
+
+// writeTimezone emits a VTIMEZONE for loc with its actual UTC offset
+// transitions from a year ago to three years ahead, taken from the Go
+// time zone database.
+func writeTimezone(iw *icsWriter, loc *time.Location, now time.Time) {
+	from := time.Date(now.Year()-1, time.January, 1, 0, 0, 0, 0, loc)
+	to := time.Date(now.Year()+3, time.January, 1, 0, 0, 0, 0, loc)
+
+	iw.line("BEGIN:VTIMEZONE")
+	iw.line("TZID:%s", loc.String())
+	prev := from
+	found := false
+	for t := from.Add(24 * time.Hour); t.Before(to); t = t.Add(24 * time.Hour) {
+		_, before := prev.Zone()
+		if _, after := t.Zone(); after != before {
+			writeTransition(iw, transition(prev, t), before)
+			found = true
+		}
+		prev = t
+	}
+	if !found {
+		// no transitions: a single fixed offset
+		name, off := from.Zone()
+		iw.line("BEGIN:STANDARD")
+		iw.line("DTSTART:19700101T000000")
+		iw.line("TZOFFSETFROM:%s", formatOffset(off))
+		iw.line("TZOFFSETTO:%s", formatOffset(off))
+		iw.line("TZNAME:%s", name)
+		iw.line("END:STANDARD")
+	}
+	iw.line("END:VTIMEZONE")
+}
+
+// transition finds the first instant in (a, b] with b's offset.
+func transition(a, b time.Time) time.Time {
+	_, target := b.Zone()
+	for b.Sub(a) > time.Second {
+		mid := a.Add(b.Sub(a) / 2)
+		if _, off := mid.Zone(); off == target {
+			b = mid
+		} else {
+			a = mid
+		}
+	}
+	return b.Truncate(time.Second)
+}
+
+func writeTransition(iw *icsWriter, at time.Time, fromOffset int) {
+	name, toOffset := at.Zone()
+	kind := "STANDARD"
+	if at.IsDST() {
+		kind = "DAYLIGHT"
+	}
+	// DTSTART is the wall-clock time just before the change
+	local := at.UTC().Add(time.Duration(fromOffset) * time.Second)
+	iw.line("BEGIN:%s", kind)
+	iw.line("DTSTART:%s", local.Format(icsLocal))
+	iw.line("TZOFFSETFROM:%s", formatOffset(fromOffset))
+	iw.line("TZOFFSETTO:%s", formatOffset(toOffset))
+	iw.line("TZNAME:%s", name)
+	iw.line("END:%s", kind)
+}
+
+// formatOffset formats seconds east of UTC as +HHMM.
+func formatOffset(sec int) string {
+	sign := '+'
+	if sec < 0 {
+		sign = '-'
+		sec = -sec
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, sec/3600, (sec%3600)/60)
+}
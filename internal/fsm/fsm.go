@@ -0,0 +1,112 @@
+// Package fsm keeps per-user dialog state machines (wizards, edit flows) in
+// SQLite so that a restart in the middle of a flow does not lose the user's
+// progress.
+package fsm
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+)
+
+// Done is the state a handler sets to end the flow.
+const Done = ""
+
+// Session is the current state of a user's flow together with its payload.
+type Session[T any] struct {
+	State string
+	Data  T
+}
+
+// Handler processes user input in a given state. It may change s.State to
+// move the flow on (or set it to Done to finish it) and edit s.Data; the
+// machine persists the result after the handler returns, even if it failed.
+type Handler[C, T any] func(c C, s *Session[T]) error
+
+// Machine stores sessions of one payload type T and routes input of context
+// type C to the handler registered for the session's current state.
+type Machine[C, T any] struct {
+	St  *store.Store
+	TTL time.Duration
+
+	handlers map[string]Handler[C, T]
+}
+
+func New[C, T any](st *store.Store, ttl time.Duration) *Machine[C, T] {
+	return &Machine[C, T]{St: st, TTL: ttl, handlers: map[string]Handler[C, T]{}}
+}
+
+// On registers the handler for a state.
+func (m *Machine[C, T]) On(state string, h Handler[C, T]) {
+	m.handlers[state] = h
+}
+
+// Get returns the user's active session, or nil if there is none or it has
+// expired.
+func (m *Machine[C, T]) Get(userID int64) (*Session[T], error) {
+	conv, err := m.St.GetConversation(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.St.Clock.Now().Unix() >= conv.ExpiresAt {
+		return nil, m.St.DeleteConversation(userID)
+	}
+	s := &Session[T]{State: conv.State}
+	if err := json.Unmarshal([]byte(conv.Data), &s.Data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Set saves the session and extends its expiry. A session in the Done state
+// is removed instead.
+func (m *Machine[C, T]) Set(userID int64, s *Session[T]) error {
+	if s.State == Done {
+		return m.Reset(userID)
+	}
+	data, err := json.Marshal(s.Data)
+	if err != nil {
+		return err
+	}
+	return m.St.SaveConversation(store.Conversation{
+		TGID:      userID,
+		State:     s.State,
+		Data:      string(data),
+		ExpiresAt: m.St.Clock.Now().Add(m.TTL).Unix(),
+	})
+}
+
+// Start begins a new flow, replacing any session the user had.
+func (m *Machine[C, T]) Start(userID int64, state string, data T) error {
+	return m.Set(userID, &Session[T]{State: state, Data: data})
+}
+
+// Reset abandons the user's flow.
+func (m *Machine[C, T]) Reset(userID int64) error {
+	return m.St.DeleteConversation(userID)
+}
+
+// Dispatch feeds input to the handler of the user's current state and saves
+// the resulting session. handled is false when the user has no active
+// session or no handler is registered for its state.
+func (m *Machine[C, T]) Dispatch(userID int64, c C) (handled bool, err error) {
+	s, err := m.Get(userID)
+	if err != nil || s == nil {
+		return false, err
+	}
+	h, ok := m.handlers[s.State]
+	if !ok {
+		return false, nil
+	}
+	herr := h(c, s)
+	if err := m.Set(userID, s); err != nil {
+		return true, err
+	}
+	return true, herr
+}
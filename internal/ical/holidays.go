@@ -0,0 +1,113 @@
+package ical
+
+import (
+	"strings"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+const (
+	// holidayHorizon is how far ahead repeating holidays are expanded.
+	holidayHorizon = 366
+	// maxHolidaySpan caps the days taken from one event (a long vacation).
+	maxHolidaySpan = 62
+)
+
+// AllDay reports whether the event is an all-day (DATE) event.
+func (e Event) AllDay() bool {
+	p, ok := e.Props["DTSTART"]
+	if !ok {
+		return false
+	}
+	_, allDay, err := p.Time(time.UTC)
+	return err == nil && allDay
+}
+
+// Holidays maps all-day events onto exception dates of the user: every day
+// an event covers from today on, with repeating events expanded for a year
+// ahead. Events with a time of day are skipped.
+func Holidays(events []Event, loc *time.Location, now time.Time) ([]store.Holiday, []Skipped) {
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	horizon := today.AddDate(0, 0, holidayHorizon)
+	var (
+		out     []store.Holiday
+		skipped []Skipped
+		seen    = map[string]bool{}
+	)
+	for _, e := range events {
+		if strings.EqualFold(e.Get("STATUS"), "CANCELLED") || e.Get("RECURRENCE-ID") != "" {
+			continue
+		}
+		title := strings.TrimSpace(e.Summary())
+		days, reason := holidayDays(e, today, horizon)
+		if reason != "" {
+			skipped = append(skipped, Skipped{Title: title, Reason: reason})
+			continue
+		}
+		for _, d := range days {
+			day := d.Format(timeutil.DateLayout)
+			if seen[day] {
+				continue
+			}
+			seen[day] = true
+			out = append(out, store.Holiday{Day: day, Title: title})
+		}
+	}
+	return out, skipped
+}
+
+// holidayDays lists the dates (UTC midnights) in [today, horizon) covered
+// by an all-day event.
+func holidayDays(e Event, today, horizon time.Time) ([]time.Time, string) {
+	p, ok := e.Props["DTSTART"]
+	if !ok {
+		return nil, "нет даты"
+	}
+	start, allDay, err := p.Time(time.UTC)
+	if err != nil {
+		return nil, "не удалось прочитать дату"
+	}
+	if !allDay {
+		return nil, "событие не на весь день"
+	}
+	span := 1
+	if p, ok := e.Props["DTEND"]; ok {
+		end, _, err := p.Time(time.UTC)
+		if err != nil {
+			return nil, "не удалось прочитать дату окончания"
+		}
+		span = int(end.Sub(start).Hours() / 24)
+	} else if v := e.Get("DURATION"); v != "" {
+		d, err := ParseDuration(v)
+		if err != nil {
+			return nil, "не удалось прочитать длительность"
+		}
+		span = int(d.Hours() / 24)
+	}
+	span = min(max(span, 1), maxHolidaySpan)
+
+	starts := []time.Time{start}
+	if v := e.Get("RRULE"); v != "" {
+		r, err := timeutil.ParseRecurrence(v)
+		if err != nil {
+			return nil, "сложное правило повтора (" + err.Error() + ")"
+		}
+		// occurrences that began up to span days ago may still cover today
+		starts = r.Between(start, today.AddDate(0, 0, 1-span), horizon)
+	}
+	var days []time.Time
+	for _, s := range starts {
+		for i := 0; i < span; i++ {
+			if d := s.AddDate(0, 0, i); !d.Before(today) && d.Before(horizon) {
+				days = append(days, d)
+			}
+		}
+	}
+	if len(days) == 0 {
+		return nil, "событие уже прошло"
+	}
+	return days, ""
+}
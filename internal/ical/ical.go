@@ -0,0 +1,208 @@
+// Package ical reads iCalendar (RFC 5545) files and maps their weekly
+// recurring and single events onto tasks.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prop is a content line: NAME;PARAM=VALUE:value.
+type Prop struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Event is a VEVENT with its properties (the first of each name).
+type Event struct {
+	Props map[string]Prop
+	// ExDates holds every EXDATE line; unlike most properties it repeats.
+	ExDates []Prop
+}
+
+// Get returns the value of property name, or "".
+func (e Event) Get(name string) string { return e.Props[name].Value }
+
+// Summary is the unescaped event title.
+func (e Event) Summary() string { return unescape(e.Get("SUMMARY")) }
+
+// ErrNotCalendar is returned for input without a VCALENDAR.
+var ErrNotCalendar = errors.New("ical: not a calendar")
+
+// maxLines protects against huge uploads.
+const maxLines = 200000
+
+// Parse reads the VEVENTs of a calendar. Nested components (alarms) are
+// skipped.
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		events   []Event
+		cur      *Event
+		depth    int // nesting inside the current VEVENT
+		calendar bool
+	)
+	for _, l := range lines {
+		p, ok := parseProp(l)
+		if !ok {
+			continue
+		}
+		switch {
+		case p.Name == "BEGIN" && strings.EqualFold(p.Value, "VCALENDAR"):
+			calendar = true
+		case p.Name == "BEGIN" && strings.EqualFold(p.Value, "VEVENT") && cur == nil:
+			cur = &Event{Props: map[string]Prop{}}
+		case p.Name == "BEGIN" && cur != nil:
+			depth++
+		case p.Name == "END" && cur != nil && depth > 0:
+			depth--
+		case p.Name == "END" && strings.EqualFold(p.Value, "VEVENT") && cur != nil:
+			events = append(events, *cur)
+			cur = nil
+		case cur != nil && depth == 0:
+			if p.Name == "EXDATE" {
+				cur.ExDates = append(cur.ExDates, p)
+			}
+			if _, seen := cur.Props[p.Name]; !seen {
+				cur.Props[p.Name] = p
+			}
+		}
+	}
+	if !calendar {
+		return nil, ErrNotCalendar
+	}
+	return events, nil
+}
+
+// unfold joins continuation lines (those starting with a space or tab).
+func unfold(r io.Reader) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for sc.Scan() {
+		l := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		if len(lines) >= maxLines {
+			return nil, errors.New("ical: file too large")
+		}
+		lines = append(lines, l)
+	}
+	return lines, sc.Err()
+}
+
+// parseProp splits a content line; the value starts at the first colon
+// outside a quoted parameter value.
+func parseProp(l string) (Prop, bool) {
+	quoted := false
+	colon := -1
+	for i, r := range l {
+		if r == '"' {
+			quoted = !quoted
+		}
+		if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return Prop{}, false
+	}
+	head := strings.Split(l[:colon], ";")
+	p := Prop{Name: strings.ToUpper(head[0]), Params: map[string]string{}, Value: l[colon+1:]}
+	for _, kv := range head[1:] {
+		k, v, _ := strings.Cut(kv, "=")
+		p.Params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return p, true
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, " ", `\N`, " ").Replace(s)
+}
+
+// Time parses a DATE-TIME or DATE property. Floating times are taken in
+// def; allDay is true for DATE values.
+func (p Prop) Time(def *time.Location) (t time.Time, allDay bool, err error) {
+	v := p.Value
+	if p.Params["VALUE"] == "DATE" || len(v) == 8 {
+		t, err = time.ParseInLocation("20060102", v, def)
+		return t, true, err
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err = time.Parse("20060102T150405Z", v)
+		return t, false, err
+	}
+	loc := def
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return t, false, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+	t, err = time.ParseInLocation("20060102T150405", v, loc)
+	return t, false, err
+}
+
+// ParseDuration reads an RFC 5545 duration such as PT1H30M or P1D.
+func ParseDuration(s string) (time.Duration, error) {
+	if !strings.HasPrefix(strings.TrimPrefix(s, "+"), "P") {
+		return 0, fmt.Errorf("bad duration %q", s)
+	}
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "+"), "P")
+	var d time.Duration
+	num := ""
+	inTime := false
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+		case r == 'T':
+			inTime = true
+		default:
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				return 0, fmt.Errorf("bad duration %q", s)
+			}
+			num = ""
+			switch {
+			case r == 'W':
+				d += time.Duration(n) * 7 * 24 * time.Hour
+			case r == 'D':
+				d += time.Duration(n) * 24 * time.Hour
+			case r == 'H' && inTime:
+				d += time.Duration(n) * time.Hour
+			case r == 'M' && inTime:
+				d += time.Duration(n) * time.Minute
+			case r == 'S' && inTime:
+				d += time.Duration(n) * time.Second
+			default:
+				return 0, fmt.Errorf("bad duration %q", s)
+			}
+		}
+	}
+	return d, nil
+}
+
+// Rule is a parsed RRULE: upper-case keys to values.
+type Rule map[string]string
+
+// ParseRule splits an RRULE value.
+func ParseRule(s string) Rule {
+	r := Rule{}
+	for _, part := range strings.Split(s, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		r[strings.ToUpper(k)] = strings.ToUpper(v)
+	}
+	return r
+}
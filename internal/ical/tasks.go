@@ -0,0 +1,244 @@
+package ical
+
+import (
+	"strings"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// Skipped is an event that could not be turned into a task.
+type Skipped struct {
+	Title  string
+	Reason string
+}
+
+var byDay = map[string]int{
+	"MO": timeutil.BitMon, "TU": timeutil.BitTue, "WE": timeutil.BitWed, "TH": timeutil.BitThu,
+	"FR": timeutil.BitFri, "SA": timeutil.BitSat, "SU": timeutil.BitSun,
+}
+
+// Imported is a task made from an event, with the local dates (YYYY-MM-DD)
+// on which the event's EXDATEs cancel it.
+type Imported struct {
+	Task  store.Task
+	Skips []string
+}
+
+// Tasks maps weekly (and daily) recurring events onto tasks in loc, events
+// with richer rules onto tasks keeping the rule, and single upcoming events
+// onto one-off tasks. Events the task model cannot express are returned as
+// skipped with a reason. Cancelled events and overrides of single
+// occurrences are ignored.
+func Tasks(events []Event, loc *time.Location, now time.Time) ([]Imported, []Skipped) {
+	var (
+		tasks   []Imported
+		skipped []Skipped
+	)
+	for _, e := range events {
+		if strings.EqualFold(e.Get("STATUS"), "CANCELLED") || e.Get("RECURRENCE-ID") != "" {
+			continue
+		}
+		t, reason := task(e, loc, now)
+		var skips []string
+		if reason == "" && e.Get("RRULE") != "" {
+			skips, reason = exDates(e, loc, now)
+		}
+		if reason != "" {
+			skipped = append(skipped, Skipped{Title: e.Summary(), Reason: reason})
+			continue
+		}
+		tasks = append(tasks, Imported{Task: t, Skips: skips})
+	}
+	return tasks, skipped
+}
+
+// exDates returns the local dates, from today on, of the occurrences that
+// the event's EXDATEs cancel. A date-only EXDATE cancels the occurrence
+// starting on that date in the event's own time zone.
+func exDates(e Event, loc *time.Location, now time.Time) ([]string, string) {
+	start, _, err := e.Props["DTSTART"].Time(loc)
+	if err != nil {
+		return nil, "не удалось прочитать время начала"
+	}
+	today := now.In(loc).Format(timeutil.DateLayout)
+	var (
+		out  []string
+		seen = map[string]bool{}
+	)
+	for _, p := range e.ExDates {
+		for _, v := range strings.Split(p.Value, ",") {
+			t, allDay, err := Prop{Params: p.Params, Value: v}.Time(loc)
+			if err != nil {
+				return nil, "не удалось прочитать исключённую дату (EXDATE)"
+			}
+			if allDay {
+				t = time.Date(t.Year(), t.Month(), t.Day(), start.Hour(), start.Minute(), 0, 0, start.Location())
+			}
+			if day := t.In(loc).Format(timeutil.DateLayout); day >= today && !seen[day] {
+				seen[day] = true
+				out = append(out, day)
+			}
+		}
+	}
+	return out, ""
+}
+
+func task(e Event, loc *time.Location, now time.Time) (store.Task, string) {
+	title := strings.TrimSpace(e.Summary())
+	if title == "" {
+		return store.Task{}, "нет названия"
+	}
+	startProp, ok := e.Props["DTSTART"]
+	if !ok {
+		return store.Task{}, "нет времени начала"
+	}
+	start, allDay, err := startProp.Time(loc)
+	if err != nil {
+		return store.Task{}, "не удалось прочитать время начала"
+	}
+	if allDay {
+		return store.Task{}, "событие на весь день"
+	}
+
+	var dur time.Duration
+	if p, ok := e.Props["DTEND"]; ok {
+		end, _, err := p.Time(loc)
+		if err != nil {
+			return store.Task{}, "не удалось прочитать время окончания"
+		}
+		dur = end.Sub(start)
+	} else if v := e.Get("DURATION"); v != "" {
+		if dur, err = ParseDuration(v); err != nil {
+			return store.Task{}, "не удалось прочитать длительность"
+		}
+	}
+	if dur <= 0 || dur >= 24*time.Hour {
+		return store.Task{}, "длительность не от минуты до суток"
+	}
+
+	if e.Get("RRULE") == "" {
+		return oneOff(title, start.In(loc), dur, now.In(loc))
+	}
+	rule := ParseRule(e.Get("RRULE"))
+	if !plainRule(rule) {
+		return ruleTask(title, e.Get("RRULE"), start, loc, dur, now)
+	}
+	mask := 0
+	switch rule["FREQ"] {
+	case "DAILY", "WEEKLY":
+		switch {
+		case rule["BYDAY"] != "":
+		case rule["FREQ"] == "DAILY":
+			mask = timeutil.MaskDaily()
+		default:
+			mask = timeutil.WeekdayBit(start.Weekday())
+		}
+		for _, d := range strings.Split(rule["BYDAY"], ",") {
+			if d == "" {
+				continue
+			}
+			bit, ok := byDay[d]
+			if !ok {
+				return store.Task{}, "неподдерживаемый день повтора (" + d + ")"
+			}
+			mask |= bit
+		}
+	default:
+		return store.Task{}, "неподдерживаемый повтор (" + rule["FREQ"] + ")"
+	}
+	for _, k := range []string{"BYYEARDAY", "BYWEEKNO", "BYSETPOS", "BYHOUR", "BYMINUTE"} {
+		if rule[k] != "" {
+			return store.Task{}, "сложное правило повтора (" + k + ")"
+		}
+	}
+
+	// The days are those of the event's own time zone; moving the start to
+	// the user's zone may move it to another calendar day.
+	local := start.In(loc)
+	src := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	dst := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	mask = timeutil.ShiftMask(mask, int(dst.Sub(src).Hours()/24))
+	end := local.Add(dur)
+	if end.Hour() == local.Hour() && end.Minute() == local.Minute() {
+		return store.Task{}, "длительность меньше минуты"
+	}
+
+	return store.Task{
+		Title:  title,
+		StartH: local.Hour(), StartM: local.Minute(),
+		EndH: end.Hour(), EndM: end.Minute(),
+		DaysMask: mask,
+		Enabled:  true,
+	}, ""
+}
+
+// plainRule reports whether rule is an endless daily or weekly repeat that
+// fits a days mask.
+func plainRule(rule Rule) bool {
+	if f := rule["FREQ"]; f != "DAILY" && f != "WEEKLY" {
+		return false
+	}
+	for _, k := range []string{"COUNT", "UNTIL", "BYMONTH", "BYMONTHDAY"} {
+		if rule[k] != "" {
+			return false
+		}
+	}
+	return rule["INTERVAL"] == "" || rule["INTERVAL"] == "1"
+}
+
+// ruleTask maps an event with a richer rule (INTERVAL, COUNT, UNTIL,
+// monthly and yearly repeats) onto a task keeping the rule, anchored at the
+// event's first date.
+func ruleTask(title, value string, start time.Time, loc *time.Location, dur time.Duration, now time.Time) (store.Task, string) {
+	r, err := timeutil.ParseRecurrence(value)
+	if err != nil {
+		return store.Task{}, "сложное правило повтора (" + err.Error() + ")"
+	}
+	local := start.In(loc)
+	if local.Day() != start.Day() {
+		// the rule's days are those of the event's zone and cannot be
+		// shifted like a weekday mask
+		return store.Task{}, "правило повтора попадает на другой день в вашем часовом поясе"
+	}
+	day := timeutil.StartOfDay(local)
+	if last, finite := r.Last(day); finite && (last.IsZero() || last.Format(timeutil.DateLayout) < now.In(loc).Format(timeutil.DateLayout)) {
+		return store.Task{}, "повтор уже закончился"
+	}
+	end := local.Add(dur)
+	if end.Hour() == local.Hour() && end.Minute() == local.Minute() {
+		return store.Task{}, "длительность меньше минуты"
+	}
+	rule, from := r.String(), day.Format(timeutil.DateLayout)
+	return store.Task{
+		Title:  title,
+		StartH: local.Hour(), StartM: local.Minute(),
+		EndH: end.Hour(), EndM: end.Minute(),
+		DaysMask:   r.Weekdays(day),
+		Enabled:    true,
+		RRule:      &rule,
+		RRuleStart: &from,
+	}, ""
+}
+
+// oneOff maps a single event onto a task on its date; past events are
+// skipped.
+func oneOff(title string, start time.Time, dur time.Duration, now time.Time) (store.Task, string) {
+	if start.Before(timeutil.StartOfDay(now)) {
+		return store.Task{}, "событие уже прошло"
+	}
+	end := start.Add(dur)
+	if end.Hour() == start.Hour() && end.Minute() == start.Minute() {
+		return store.Task{}, "длительность меньше минуты"
+	}
+	date := start.Format(timeutil.DateLayout)
+	return store.Task{
+		Title:  title,
+		StartH: start.Hour(), StartM: start.Minute(),
+		EndH: end.Hour(), EndM: end.Minute(),
+		DaysMask: timeutil.WeekdayBit(start.Weekday()),
+		Enabled:  true,
+		OnDate:   &date,
+	}, ""
+}
@@ -0,0 +1,211 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SMTP is a mail server that accepts mail without authentication, such as
+// a local relay.
+type SMTP struct {
+	Addr string // host:port
+	From string
+}
+
+// Email mails events to one address; the first line of the text is the
+// subject.
+type Email struct {
+	SMTP
+	To string
+}
+
+func (m Email) Notify(e Event) error {
+	text := strings.ReplaceAll(e.Text, "\r\n", "\n")
+	subject, _, _ := strings.Cut(text, "\n")
+	// a stray CR would end the header line early; Q-encoding leaves plain
+	// ASCII as is
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\n", m.From, m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", e.At.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(text, "\n", "\r\n") + "\r\n")
+	return m.send(m.To, b.String())
+}
+
+// smtpTimeout bounds one mail delivery.
+const smtpTimeout = 30 * time.Second
+
+// send is smtp.SendMail with a deadline on the whole conversation.
+func (s SMTP) send(to, msg string) error {
+	conn, err := net.DialTimeout("tcp", s.Addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	host, _, _ := net.SplitHostPort(s.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// webhookTimeout bounds one webhook call.
+const webhookTimeout = 10 * time.Second
+
+// webhookClient connects only to public addresses: the check runs on the
+// address actually dialed, after DNS resolution and on every redirect, so
+// a host that resolves to an internal address later is refused as well.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: webhookTimeout, Control: publicOnly}).DialContext,
+		// no proxy: it would be the one dialed and checked
+		Proxy:               nil,
+		TLSHandshakeTimeout: webhookTimeout,
+	},
+}
+
+// ErrPrivateAddress is returned for webhooks on loopback, private,
+// link-local and other non-public addresses.
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// cgnat is the shared address space of RFC 6598.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr reports whether ip is a public unicast address.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() && ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() &&
+		!ip.IsLinkLocalUnicast() && !cgnat.Contains(ip)
+}
+
+// publicOnly is a net.Dialer Control refusing non-public addresses.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(ap.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ap.Addr())
+	}
+	return nil
+}
+
+// CheckWebhookURL validates a webhook URL given by a user: http(s) only,
+// and the host must resolve to public addresses.
+func CheckWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("not an http(s) URL")
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		if !publicAddr(a) {
+			return fmt.Errorf("%w: %s", ErrPrivateAddress, a.Unmap())
+		}
+	}
+	return nil
+}
+
+// Webhook posts events as JSON to a URL.
+type Webhook struct {
+	URL string
+}
+
+func (w Webhook) Notify(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(w.URL, "application/json", bytes.NewReader(body))
+	if errors.Is(err, ErrPrivateAddress) {
+		return fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	err = fmt.Errorf("webhook %s: %s", w.URL, resp.Status)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		secs, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &RetryError{After: time.Duration(secs) * time.Second, Err: err}
+	}
+	return err
+}
+
+// Log appends events as JSON lines to a file.
+type Log struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenLog opens (or creates) the log file for appending.
+func OpenLog(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{f: f}, nil
+}
+
+func (l *Log) Notify(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.f.Write(append(line, '\n'))
+	return err
+}
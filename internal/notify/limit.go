@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"sync"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// Telegram's limits on bot messages: about 30 per second in total and one
+// per second to the same chat.
+const (
+	telegramRate = 30
+	chatInterval = time.Second
+)
+
+// bucket is a token bucket holding up to burst tokens and refilled at rate
+// tokens per second.
+type bucket struct {
+	mu     sync.Mutex
+	clock  timeutil.Clock
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(clock timeutil.Clock, rate int) *bucket {
+	return &bucket{clock: clock, rate: float64(rate), burst: float64(rate), tokens: float64(rate), last: clock.Now()}
+}
+
+// refill adds the tokens accrued since the last call; b.mu must be held.
+func (b *bucket) refill() {
+	now := b.clock.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// take removes a token, waiting for one if the bucket is empty.
+func (b *bucket) take() {
+	for {
+		b.mu.Lock()
+		b.refill()
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+		<-b.clock.After(wait)
+	}
+}
+
+// hold empties the bucket so that the next token comes after d.
+func (b *bucket) hold(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens = min(b.tokens, -d.Seconds()*b.rate+1)
+}
+
+// pacer spaces messages to the same chat at least interval apart.
+type pacer struct {
+	interval time.Duration
+	last     map[int64]time.Time
+}
+
+func newPacer(interval time.Duration) *pacer {
+	return &pacer{interval: interval, last: map[int64]time.Time{}}
+}
+
+// wait returns how long the chat has to wait at now for its next message.
+func (p *pacer) wait(chat int64, now time.Time) time.Duration {
+	last, ok := p.last[chat]
+	if !ok {
+		return 0
+	}
+	return max(0, p.interval-now.Sub(last))
+}
+
+// sent records a message to the chat and forgets chats that are ready
+// again.
+func (p *pacer) sent(chat int64, now time.Time) {
+	for c, t := range p.last {
+		if now.Sub(t) >= p.interval {
+			delete(p.last, c)
+		}
+	}
+	p.last[chat] = now
+}
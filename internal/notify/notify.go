@@ -0,0 +1,139 @@
+// Package notify delivers the scheduler's events to users: by Telegram and,
+// for users who set them up with /notify, by email, webhook or a log file.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+)
+
+// Event kinds.
+const (
+	KindStart    = "start"    // a task occurrence starts (prompt with buttons)
+	KindFinish   = "finish"   // a task occurrence finishes (prompt with buttons)
+	KindReminder = "reminder" // a start or finish is coming up
+	KindDigest   = "digest"   // a summary of several tasks or days
+	KindNotice   = "notice"   // anything else: settled prompts, timers, missed events
+)
+
+// Event is one notification for a user.
+type Event struct {
+	Kind   string    `json:"kind"`
+	UserID int64     `json:"user_id"`
+	ChatID int64     `json:"chat_id"` // the user's Telegram chat
+	TaskID int64     `json:"task_id,omitempty"`
+	Title  string    `json:"title,omitempty"` // task or timer title
+	Day    string    `json:"day,omitempty"`   // occurrence day (YYYY-MM-DD) of prompts
+	At     time.Time `json:"at"`              // when the event happens
+	Text   string    `json:"text"`            // the message shown to the user
+}
+
+// ForUser starts an event for u.
+func ForUser(u store.User, kind string, at time.Time, text string) Event {
+	return Event{Kind: kind, UserID: u.ID, ChatID: u.TGID, At: at, Text: text}
+}
+
+// Key identifies the event for deduplication: one event of a kind per task
+// occurrence and time. Events not about a task have no key.
+func (e Event) Key() string {
+	if e.TaskID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%s:%d", e.Kind, e.TaskID, e.Day, e.At.Unix())
+}
+
+// Priority orders delivery when sends are rate limited: lower goes first.
+// Prompts that expect an answer beat reminders, which beat the rest.
+func (e Event) Priority() int {
+	switch e.Kind {
+	case KindStart, KindFinish:
+		return 0
+	case KindReminder:
+		return 1
+	case KindDigest:
+		return 3
+	}
+	return 2
+}
+
+// Notifier delivers events.
+type Notifier interface {
+	Notify(e Event) error
+}
+
+// Func adapts a function to Notifier.
+type Func func(e Event) error
+
+func (f Func) Notify(e Event) error { return f(e) }
+
+// Router sends every event by Telegram and by the extra channels the user
+// has set up.
+type Router struct {
+	Telegram Notifier
+	St       *store.Store
+	// SMTP is the mail server of the email channel; a zero value disables it.
+	SMTP SMTP
+	// Log collects the events of users with the log channel; nil disables it.
+	Log *Log
+}
+
+// Available reports whether the server supports a channel kind.
+func (r *Router) Available(kind string) bool {
+	switch kind {
+	case store.ChannelEmail:
+		return r.SMTP.Addr != ""
+	case store.ChannelWebhook:
+		return true
+	case store.ChannelLog:
+		return r.Log != nil
+	}
+	return false
+}
+
+// Channels returns where the user's events go: Telegram first, then the
+// confirmed extra channels the server supports.
+func (r *Router) Channels(userID int64) ([]store.NotifyChannel, error) {
+	extra, err := r.St.ListNotifyChannels(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := []store.NotifyChannel{{UserID: userID, Kind: store.ChannelTelegram}}
+	for _, ch := range extra {
+		if r.Available(ch.Kind) && ch.Confirmed() {
+			out = append(out, ch)
+		}
+	}
+	return out, nil
+}
+
+// Notify delivers the event to all of the user's channels right away.
+func (r *Router) Notify(e Event) error {
+	channels, err := r.Channels(e.UserID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ch := range channels {
+		errs = append(errs, r.For(ch).Notify(e))
+	}
+	return errors.Join(errs...)
+}
+
+// For returns the notifier of a channel.
+func (r *Router) For(ch store.NotifyChannel) Notifier {
+	switch {
+	case ch.Kind == store.ChannelTelegram:
+		return r.Telegram
+	case !r.Available(ch.Kind):
+	case ch.Kind == store.ChannelEmail:
+		return Email{SMTP: r.SMTP, To: ch.Target}
+	case ch.Kind == store.ChannelWebhook:
+		return Webhook{URL: ch.Target}
+	case ch.Kind == store.ChannelLog:
+		return r.Log
+	}
+	return Func(func(Event) error { return fmt.Errorf("%w: channel %q is not available", ErrUnreachable, ch.Kind) })
+}
@@ -0,0 +1,286 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+)
+
+// ErrUnreachable means the recipient cannot get messages on the channel any
+// more (the bot was blocked, the account deleted); retrying is pointless.
+var ErrUnreachable = errors.New("recipient unreachable")
+
+// RetryError asks to try again no earlier than After (Telegram's
+// retry_after, a webhook's Retry-After).
+type RetryError struct {
+	After time.Duration
+	Err   error
+}
+
+func (e *RetryError) Error() string { return fmt.Sprintf("%v (retry after %s)", e.Err, e.After) }
+func (e *RetryError) Unwrap() error { return e.Err }
+
+const (
+	// outboxBatch is how many due messages are loaded at a time.
+	outboxBatch = 50
+	// outboxIdle is the longest a worker sleeps without being woken.
+	outboxIdle = time.Minute
+	// outboxLease is how long a message being sent is not picked up again;
+	// longer than any send may take.
+	outboxLease = 2 * time.Minute
+	// retryBase is the delay after the first failure; it doubles with each
+	// further attempt up to retryMax.
+	retryBase = 5 * time.Second
+	retryMax  = 10 * time.Minute
+	// maxAttempts is when a message is given up (about 20 minutes of tries).
+	maxAttempts = 8
+)
+
+// Delivery lanes: Telegram has its own, so slow mail or webhook servers
+// never hold up the prompts.
+const (
+	laneTelegram = iota
+	laneOther
+	lanes
+)
+
+// laneWorkers bounds the sends in flight per lane. Telegram's is sized so
+// that API round trips of ~100 ms still reach telegramRate.
+var laneWorkers = [lanes]int{laneTelegram: 8, laneOther: 4}
+
+// Outbox queues events in the store, one message per channel, and delivers
+// them in the background, retrying failures with exponential backoff. An
+// event whose key was queued before is dropped, so a task occurrence is
+// announced once even if its job runs twice.
+//
+// Telegram messages are sent within the API limits: a token bucket keeps to
+// the global rate, chats get at most one message per chatInterval, and due
+// messages go by Event.Priority so prompts are not stuck behind digests.
+// Other channels are delivered on a lane of their own.
+type Outbox struct {
+	Router *Router
+	// OnBlocked is called when a user turns out to be unreachable on
+	// Telegram; their pending Telegram messages are dropped before.
+	OnBlocked func(userID int64)
+
+	wake    [lanes]chan struct{}
+	slots   [lanes]chan struct{} // semaphores of the sends in flight
+	flushMu [lanes]sync.Mutex    // one flush per lane at a time
+	sending sync.WaitGroup
+
+	bucket *bucket
+
+	mu    sync.Mutex // guards pacer
+	pacer *pacer
+}
+
+// NewOutbox returns an outbox delivering through r; call Start to run it.
+func NewOutbox(r *Router) *Outbox {
+	o := &Outbox{
+		Router: r,
+		bucket: newBucket(r.St.Clock, telegramRate),
+		pacer:  newPacer(chatInterval),
+	}
+	for l := range lanes {
+		o.wake[l] = make(chan struct{}, 1)
+		o.slots[l] = make(chan struct{}, laneWorkers[l])
+	}
+	return o
+}
+
+func (o *Outbox) Notify(e Event) error {
+	channels, err := o.Router.Channels(e.UserID)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	var key *string
+	if k := e.Key(); k != "" {
+		key = &k
+	}
+	var errs []error
+	for _, ch := range channels {
+		_, err := o.Router.St.Enqueue(store.OutboxMessage{UserID: e.UserID, Channel: ch.Kind, Target: ch.Target,
+			DedupKey: key, Event: string(body), Priority: e.Priority()})
+		errs = append(errs, err)
+	}
+	for l := range lanes {
+		o.kick(l)
+	}
+	return errors.Join(errs...)
+}
+
+// kick wakes the worker of a lane.
+func (o *Outbox) kick(l int) {
+	select {
+	case o.wake[l] <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the delivery workers in the background.
+func (o *Outbox) Start() {
+	for l := range lanes {
+		go o.run(l)
+	}
+}
+
+func (o *Outbox) run(l int) {
+	clock := o.Router.St.Clock
+	for {
+		paced := o.flush(l)
+		wait := outboxIdle
+		if at, ok, err := o.Router.St.NextOutboxAt(l == laneTelegram); err == nil && ok {
+			switch d := at.Sub(clock.Now()); {
+			case d > 0:
+				wait = min(wait, d)
+			case paced > 0:
+				// what is due waits for its chats
+				wait = min(wait, paced)
+			default:
+				// due now, yet nothing is paced and nothing was handed over
+				// (the claims were lost or every sender was busy); look
+				// again soon
+				wait = min(wait, time.Second)
+			}
+		}
+		select {
+		case <-o.wake[l]:
+		case <-clock.After(wait):
+		}
+	}
+}
+
+// Flush hands the messages due now to the senders and waits until they are
+// delivered or rescheduled.
+func (o *Outbox) Flush() {
+	for l := range lanes {
+		o.flush(l)
+	}
+	o.sending.Wait()
+}
+
+// flush starts sending the lane's due messages. Telegram messages to chats
+// that got one too recently stay queued; paced is how long until the first
+// of those chats is ready, zero if none.
+func (o *Outbox) flush(l int) (paced time.Duration) {
+	o.flushMu[l].Lock()
+	defer o.flushMu[l].Unlock()
+	st := o.Router.St
+	for {
+		due, err := st.DueOutbox(st.Clock.Now(), outboxBatch, l == laneTelegram)
+		if err != nil {
+			log.Println("outbox:", err)
+			return paced
+		}
+		// chats skipped in this batch: their later messages wait too, to
+		// keep the order
+		skipped := map[int64]bool{}
+		started := 0
+		for _, m := range due {
+			var e Event
+			if err := json.Unmarshal([]byte(m.Event), &e); err != nil {
+				o.settle(m, err)
+				continue
+			}
+			if l == laneTelegram {
+				o.mu.Lock()
+				wait := o.pacer.wait(e.ChatID, st.Clock.Now())
+				o.mu.Unlock()
+				if skipped[e.ChatID] || wait > 0 {
+					skipped[e.ChatID] = true
+					if paced == 0 || (wait > 0 && wait < paced) {
+						paced = max(wait, time.Millisecond)
+					}
+					continue
+				}
+			}
+			if o.send(l, m, e) {
+				started++
+			}
+		}
+		if len(due) < outboxBatch || started == 0 {
+			return paced
+		}
+	}
+}
+
+// send delivers one message on a free sender of the lane, waiting for one
+// if all are busy; it reports whether the message was handed over.
+func (o *Outbox) send(l int, m store.OutboxMessage, e Event) bool {
+	st := o.Router.St
+	o.slots[l] <- struct{}{}
+	if ok, err := st.ClaimOutbox(m.ID, st.Clock.Now().Add(outboxLease)); err != nil || !ok {
+		if err != nil {
+			log.Println("outbox:", err)
+		}
+		<-o.slots[l]
+		return false
+	}
+	if l == laneTelegram {
+		// a token only for a message that will really go out
+		o.bucket.take()
+		o.mu.Lock()
+		o.pacer.sent(e.ChatID, st.Clock.Now())
+		o.mu.Unlock()
+	}
+	o.sending.Add(1)
+	go func() {
+		defer o.sending.Done()
+		err := o.Router.For(store.NotifyChannel{UserID: m.UserID, Kind: m.Channel, Target: m.Target}).Notify(e)
+		var retry *RetryError
+		if l == laneTelegram && errors.As(err, &retry) {
+			// a flood limit holds back all of the bot's messages
+			o.bucket.hold(retry.After)
+		}
+		o.settle(m, err)
+		<-o.slots[l]
+		o.kick(l)
+	}()
+	return true
+}
+
+// settle records the outcome of a delivery attempt.
+func (o *Outbox) settle(m store.OutboxMessage, err error) {
+	st := o.Router.St
+	now := st.Clock.Now()
+	var retry *RetryError
+	switch {
+	case err == nil:
+		err = st.MarkSent(m.ID, now)
+	case errors.Is(err, ErrUnreachable):
+		log.Printf("outbox %d: user %d unreachable on %s: %v", m.ID, m.UserID, m.Channel, err)
+		err = errors.Join(st.MarkFailed(m.ID, err.Error()), st.FailPending(m.UserID, m.Channel, err.Error()))
+		if m.Channel == store.ChannelTelegram && o.OnBlocked != nil {
+			o.OnBlocked(m.UserID)
+		}
+	case m.Attempts+1 >= maxAttempts:
+		log.Printf("outbox %d: giving up after %d attempts: %v", m.ID, m.Attempts+1, err)
+		err = st.MarkFailed(m.ID, err.Error())
+	case errors.As(err, &retry):
+		err = st.MarkRetry(m.ID, now.Add(max(retry.After, backoff(m.Attempts))), err.Error())
+	default:
+		err = st.MarkRetry(m.ID, now.Add(backoff(m.Attempts)), err.Error())
+	}
+	if err != nil {
+		log.Println("outbox:", err)
+	}
+}
+
+// backoff is the delay before the next try after the given number of
+// failed attempts.
+func backoff(attempts int) time.Duration {
+	d := retryBase << attempts
+	if d <= 0 || d > retryMax {
+		return retryMax
+	}
+	return d
+}
@@ -0,0 +1,26 @@
+package notify
+
+import "sync"
+
+// Recorder keeps the events it is given instead of delivering them; use it
+// in place of a real notifier to see what the scheduler sends.
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+	// Err, if set, is returned by every Notify call.
+	Err error
+}
+
+func (r *Recorder) Notify(e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return r.Err
+}
+
+// Events returns a copy of the recorded events.
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
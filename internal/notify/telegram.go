@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v3"
+)
+
+// Inline button uniques of the start/finish prompts. The bot package
+// registers handlers for them.
+const (
+	BtnStarted  = "occ_started"
+	BtnSnooze   = "occ_snooze"
+	BtnSkip     = "occ_skip"
+	BtnFinished = "occ_finished"
+	BtnEarlier  = "occ_earlier"
+	BtnStillOn  = "occ_still"
+)
+
+// OccurrenceData encodes the callback data of prompt buttons.
+func OccurrenceData(taskID int64, day string) string { return fmt.Sprintf("%d:%s", taskID, day) }
+
+// ParseOccurrenceData is the inverse of OccurrenceData.
+func ParseOccurrenceData(data string) (taskID int64, day string, ok bool) {
+	id, day, found := strings.Cut(data, ":")
+	if !found {
+		return 0, "", false
+	}
+	taskID, err := strconv.ParseInt(id, 10, 64)
+	return taskID, day, err == nil && day != ""
+}
+
+// Telegram sends events to the user's chat; start and finish prompts get
+// their answer buttons.
+type Telegram struct {
+	Bot *telebot.Bot
+}
+
+func (t Telegram) Notify(e Event) error {
+	chat := &telebot.Chat{ID: e.ChatID}
+	var err error
+	switch {
+	case e.Kind == KindStart && e.Day != "":
+		_, err = t.Bot.Send(chat, e.Text, startMarkup(e.TaskID, e.Day))
+	case e.Kind == KindFinish && e.Day != "":
+		_, err = t.Bot.Send(chat, e.Text, finishMarkup(e.TaskID, e.Day))
+	default:
+		_, err = t.Bot.Send(chat, e.Text)
+	}
+	return telegramError(err)
+}
+
+// telegramError translates the API errors the outbox acts upon.
+func telegramError(err error) error {
+	var flood telebot.FloodError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &flood):
+		return &RetryError{After: time.Duration(flood.RetryAfter) * time.Second, Err: err}
+	case errors.Is(err, telebot.ErrBlockedByUser), errors.Is(err, telebot.ErrUserIsDeactivated),
+		errors.Is(err, telebot.ErrChatNotFound):
+		return fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	return err
+}
+
+func startMarkup(taskID int64, day string) *telebot.ReplyMarkup {
+	data := OccurrenceData(taskID, day)
+	mk := &telebot.ReplyMarkup{}
+	mk.Inline(
+		mk.Row(mk.Data("▶️ Начал", BtnStarted, data), mk.Data("⏰ Отложить на 10 мин", BtnSnooze, data)),
+		mk.Row(mk.Data("⏭ Пропустить", BtnSkip, data)),
+	)
+	return mk
+}
+
+func finishMarkup(taskID int64, day string) *telebot.ReplyMarkup {
+	data := OccurrenceData(taskID, day)
+	mk := &telebot.ReplyMarkup{}
+	mk.Inline(
+		mk.Row(mk.Data("✅ Закончил", BtnFinished, data)),
+		mk.Row(mk.Data("⏪ Закончил раньше", BtnEarlier, data), mk.Data("⏩ Ещё работаю", BtnStillOn, data)),
+	)
+	return mk
+}
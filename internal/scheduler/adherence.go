@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+const (
+	// LateGrace is how far past the planned start (or finish) a run may
+	// begin (or end) before it counts as a late start (or an overrun).
+	LateGrace = 5 * time.Minute
+	// earlySlack lets a run started shortly before the planned start count
+	// towards that occurrence.
+	earlySlack = 30 * time.Minute
+)
+
+// AdherenceRow compares one task's plan with what was recorded. Times are
+// in seconds.
+type AdherenceRow struct {
+	TaskID      int64
+	Title       string
+	Occurrences int   // finished occurrences in the period
+	Missed      int   // occurrences without any run
+	Late        int   // first run began more than LateGrace after the start
+	Overruns    int   // last run ended more than LateGrace after the finish
+	Planned     int64 // planned time of those occurrences
+	Covered     int64 // part of the planned time covered by runs
+	Actual      int64 // full duration of the matched runs
+	Unplanned   int64 // runs of the task outside any occurrence
+}
+
+// Percent is the share of planned time covered by runs.
+func (r AdherenceRow) Percent() int {
+	if r.Planned == 0 {
+		return 0
+	}
+	return int(r.Covered * 100 / r.Planned)
+}
+
+// Adherence compares the user's plan over r with the recorded task runs.
+// Only occurrences that started in r and have already finished are counted,
+// using each task's current schedule from its creation until it was
+// archived. Disabled tasks, holidays and old tasks of unknown age (no
+// creation time, never run) have no plan but their runs are reported.
+func (sc *Scheduler) Adherence(u store.User, r timeutil.Range) ([]AdherenceRow, error) {
+	tasks, err := sc.St.AllTasks(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := sc.St.ListRuns(u.ID, r.From.Add(-earlySlack).UTC(), timeutil.AddDays(r.To, 1).UTC())
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := sc.St.GetHolidays(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	firstRuns, err := sc.St.FirstRuns(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return adherence(tasks, runs, firstRuns, holidays, r, sc.Clock.Now().In(r.From.Location())), nil
+}
+
+// adherence does the work of Adherence; firstRuns holds the start (unix) of
+// each task's earliest run ever.
+func adherence(tasks []store.Task, runs []store.TaskRun, firstRuns map[int64]int64, holidays store.Holidays, r timeutil.Range, now time.Time) []AdherenceRow {
+	byTask := map[int64][]store.TaskRun{}
+	for _, run := range runs {
+		if run.TaskID == nil {
+			continue
+		}
+		byTask[*run.TaskID] = append(byTask[*run.TaskID], run)
+	}
+	end := r.To
+	if now.Before(end) {
+		end = now
+	}
+
+	var out []AdherenceRow
+	for _, t := range tasks {
+		row := AdherenceRow{TaskID: t.ID, Title: t.Title}
+		taskRuns := byTask[t.ID]
+		used := make([]bool, len(taskRuns))
+
+		// The plan window: from creation (or, if unknown, the task's first
+		// recorded run) until archiving or now. A task with neither has no
+		// plan: it would otherwise be planned back to the start of r.
+		from, to := r.From, end
+		created, ok := firstRuns[t.ID]
+		if t.CreatedAt != nil {
+			created, ok = *t.CreatedAt, true
+		}
+		if c := time.Unix(created, 0).In(from.Location()); ok && c.After(from) {
+			from = c
+		}
+		if t.ArchivedAt != nil {
+			if a := time.Unix(*t.ArchivedAt, 0).In(to.Location()); a.Before(to) {
+				to = a
+			}
+		}
+
+		if t.Enabled && ok {
+			for d := timeutil.StartOfDay(from); d.Before(to); d = timeutil.AddDays(d, 1) {
+				s, e, ok := occurrence(t, d)
+				if !ok || s.Before(from) || !s.Before(r.To) || e.After(to) || holidays.Skips(t.ID, s) {
+					continue
+				}
+				row.Occurrences++
+				row.Planned += int64(e.Sub(s).Seconds())
+
+				var first, last time.Time
+				matched := false
+				for i, run := range taskRuns {
+					rs, re := runSpan(run, now)
+					if used[i] || !rs.Before(e) || (!re.After(s) && rs.Before(s.Add(-earlySlack))) {
+						continue
+					}
+					used[i] = true
+					if !matched || rs.Before(first) {
+						first = rs
+					}
+					if !matched || re.After(last) {
+						last = re
+					}
+					matched = true
+					row.Actual += int64(re.Sub(rs).Seconds())
+					row.Covered += overlap(rs, re, s, e)
+				}
+				switch {
+				case !matched:
+					row.Missed++
+				default:
+					if first.After(s.Add(LateGrace)) {
+						row.Late++
+					}
+					if last.After(e.Add(LateGrace)) {
+						row.Overruns++
+					}
+				}
+			}
+		}
+
+		for i, run := range taskRuns {
+			if used[i] {
+				continue
+			}
+			rs, re := runSpan(run, now)
+			row.Unplanned += overlap(rs, re, r.From, r.To)
+		}
+		if row.Occurrences > 0 || row.Unplanned > 0 {
+			out = append(out, row)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Planned > out[j].Planned })
+	return out
+}
+
+// runSpan returns the run's interval; open runs last until now.
+func runSpan(run store.TaskRun, now time.Time) (time.Time, time.Time) {
+	start := time.Unix(run.StartTs, 0).In(now.Location())
+	if run.EndTs == nil {
+		return start, now
+	}
+	return start, time.Unix(*run.EndTs, 0).In(now.Location())
+}
+
+// overlap is the length in seconds of [s1, e1) ∩ [s2, e2).
+func overlap(s1, e1, s2, e2 time.Time) int64 {
+	if s2.After(s1) {
+		s1 = s2
+	}
+	if e2.Before(e1) {
+		e1 = e2
+	}
+	if !e1.After(s1) {
+		return 0
+	}
+	return int64(e1.Sub(s1).Seconds())
+}
@@ -0,0 +1,236 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/okpulse/telegram-schedule-bot/internal/notify"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+const (
+	// SnoozeStep is how far "Отложить" moves the start prompt.
+	SnoozeStep = 10 * time.Minute
+	// ExtendStep is when the finish prompt is repeated after "Ещё работаю".
+	ExtendStep = 15 * time.Minute
+)
+
+// ErrStartSettled is returned for an answer to a start prompt whose
+// occurrence was settled already (started, skipped, counted by policy) or
+// is no longer current.
+var ErrStartSettled = errors.New("start prompt is settled")
+
+// OccurrenceDay is the key of the occurrence that starts at start (local).
+func OccurrenceDay(start time.Time) string { return start.Format("2006-01-02") }
+
+func (sc *Scheduler) promptTag(userID int64) string { return fmt.Sprintf("prompt:%d", userID) }
+
+// at schedules fn once at the given time under the user's prompt tag, which
+// survives re-planning of the day's schedule.
+func (sc *Scheduler) at(u store.User, when time.Time, fn func()) {
+	_, _ = sc.S.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(when.UTC())),
+		gocron.NewTask(fn),
+		gocron.WithTags(sc.promptTag(u.ID)),
+	)
+}
+
+func (sc *Scheduler) timeout(u store.User) time.Duration {
+	m := u.ConfirmTimeout
+	if m <= 0 {
+		m = store.DefaultConfirmTimeout
+	}
+	return time.Duration(m) * time.Minute
+}
+
+// promptStart asks the user whether the task has started and arranges for
+// the answer to be settled by policy if none arrives in time.
+func (sc *Scheduler) promptStart(userTGID, taskID int64, day string, at time.Time) {
+	u, err := sc.St.GetUserByTGID(userTGID)
+	if err != nil || !u.ControlEnabled {
+		return
+	}
+	t, err := sc.St.GetTask(u.ID, taskID)
+	if err != nil || !t.Enabled || t.ArchivedAt != nil {
+		return
+	}
+	if err := sc.St.SetStartStatus(u.ID, t.ID, day, store.OccPrompted, at); err != nil {
+		return
+	}
+	sc.notify(sc.taskEvent(u, t, notify.KindStart, day, at, "🔔Старт задачи: "+t.Title))
+	sc.at(u, at.Add(sc.timeout(u)), func() { sc.resolveStart(userTGID, taskID, day, at) })
+}
+
+// resolveStart settles a start prompt that is still unanswered.
+func (sc *Scheduler) resolveStart(userTGID, taskID int64, day string, promptAt time.Time) {
+	u, err := sc.St.GetUserByTGID(userTGID)
+	if err != nil {
+		return
+	}
+	o, err := sc.St.GetOccurrence(u.ID, taskID, day)
+	if err != nil || o.StartStatus != store.OccPrompted || o.StartPromptAt == nil || *o.StartPromptAt != promptAt.Unix() {
+		return
+	}
+	t, err := sc.St.GetTask(u.ID, taskID)
+	if err != nil {
+		return
+	}
+	sc.settleStart(u, t, o, true)
+}
+
+// settleStart applies the user's confirmation policy to an unanswered start.
+func (sc *Scheduler) settleStart(u store.User, t store.Task, o store.Occurrence, announce bool) {
+	if o.StartPromptAt == nil {
+		return
+	}
+	at := time.Unix(*o.StartPromptAt, 0)
+	if u.ConfirmPolicy == store.ConfirmManual {
+		_ = sc.St.SetStartStatus(u.ID, t.ID, o.Day, store.OccSkipped, time.Time{})
+		if announce {
+			sc.notify(sc.taskEvent(u, t, notify.KindNotice, o.Day, at, "⏭ Нет ответа — «"+t.Title+"» не учтена."))
+		}
+		return
+	}
+	if err := sc.St.StartRun(u.ID, t.ID, at.UTC()); errors.Is(err, store.ErrRunOpen) {
+		// a manual timer is counting the time already
+		_ = sc.St.SetStartStatus(u.ID, t.ID, o.Day, store.OccSkipped, time.Time{})
+		if announce {
+			sc.notify(sc.taskEvent(u, t, notify.KindNotice, o.Day, at, "⏱ Идёт таймер — старт «"+t.Title+"» не учтён."))
+		}
+		return
+	}
+	_ = sc.St.SetStartStatus(u.ID, t.ID, o.Day, store.OccAuto, time.Time{})
+	if announce {
+		loc, _ := time.LoadLocation(u.TZ)
+		sc.notify(sc.taskEvent(u, t, notify.KindNotice, o.Day, at,
+			fmt.Sprintf("▶️ Нет ответа — старт «%s» засчитан по плану (%s).", t.Title, at.In(loc).Format("15:04"))))
+	}
+}
+
+// promptFinish asks the user to confirm the finish of a running task.
+func (sc *Scheduler) promptFinish(userTGID, taskID int64, day string, at time.Time) {
+	u, err := sc.St.GetUserByTGID(userTGID)
+	if err != nil || !u.ControlEnabled {
+		return
+	}
+	t, err := sc.St.GetTask(u.ID, taskID)
+	if err != nil {
+		return
+	}
+	o, err := sc.St.GetOccurrence(u.ID, t.ID, day)
+	if err != nil {
+		return
+	}
+	if o.StartStatus == store.OccPrompted || o.StartStatus == store.OccSnoozed {
+		sc.settleStart(u, t, o, false)
+	}
+	open, err := sc.St.HasOpenRun(u.ID, t.ID)
+	if err != nil || !open {
+		return
+	}
+	if err := sc.St.SetEndStatus(u.ID, t.ID, day, store.OccPrompted, at); err != nil {
+		return
+	}
+	sc.notify(sc.taskEvent(u, t, notify.KindFinish, day, at, "✅Финиш задачи: "+t.Title))
+	sc.at(u, at.Add(sc.timeout(u)), func() { sc.resolveFinish(userTGID, taskID, day, at) })
+}
+
+// resolveFinish closes the run at the prompt time if the user did not answer.
+func (sc *Scheduler) resolveFinish(userTGID, taskID int64, day string, promptAt time.Time) {
+	u, err := sc.St.GetUserByTGID(userTGID)
+	if err != nil {
+		return
+	}
+	o, err := sc.St.GetOccurrence(u.ID, taskID, day)
+	if err != nil || o.EndStatus != store.OccPrompted || o.EndPromptAt == nil || *o.EndPromptAt != promptAt.Unix() {
+		return
+	}
+	_ = sc.St.EndRun(u.ID, taskID, promptAt.UTC())
+	_ = sc.St.SetEndStatus(u.ID, taskID, day, store.OccAuto, time.Time{})
+}
+
+// awaitingStart checks that the occurrence still waits for an answer to its
+// start prompt: it was prompted or snoozed, and it starts today or is an
+// overnight occurrence from yesterday that has not finished yet.
+func (sc *Scheduler) awaitingStart(u store.User, taskID int64, day string) error {
+	o, err := sc.St.GetOccurrence(u.ID, taskID, day)
+	if err != nil {
+		return err
+	}
+	if o.StartStatus != store.OccPrompted && o.StartStatus != store.OccSnoozed {
+		return ErrStartSettled
+	}
+	loc, err := time.LoadLocation(u.TZ)
+	if err != nil {
+		return err
+	}
+	now := sc.Clock.Now().In(loc)
+	if day == OccurrenceDay(now) {
+		return nil
+	}
+	t, err := sc.St.GetTask(u.ID, taskID)
+	if err != nil {
+		return err
+	}
+	if t.Overnight() && day == OccurrenceDay(timeutil.AddDays(now, -1)) &&
+		now.Before(timeutil.DateTimeOn(timeutil.AddDays(now, -1), t.EndH, t.EndM, 1)) {
+		return nil
+	}
+	return ErrStartSettled
+}
+
+// ConfirmStart opens the run at the moment the user pressed "Начал".
+func (sc *Scheduler) ConfirmStart(u store.User, taskID int64, day string, at time.Time) error {
+	if err := sc.awaitingStart(u, taskID, day); err != nil {
+		return err
+	}
+	if err := sc.St.StartRun(u.ID, taskID, at.UTC()); err != nil {
+		return err
+	}
+	return sc.St.SetStartStatus(u.ID, taskID, day, store.OccConfirmed, time.Time{})
+}
+
+// SnoozeStart repeats the start prompt after SnoozeStep and returns its time.
+func (sc *Scheduler) SnoozeStart(u store.User, taskID int64, day string) (time.Time, error) {
+	next := sc.Clock.Now().Add(SnoozeStep)
+	if err := sc.awaitingStart(u, taskID, day); err != nil {
+		return next, err
+	}
+	if err := sc.St.SetStartStatus(u.ID, taskID, day, store.OccSnoozed, time.Time{}); err != nil {
+		return next, err
+	}
+	sc.at(u, next, func() {
+		// the user may have answered in the meantime
+		if o, err := sc.St.GetOccurrence(u.ID, taskID, day); err == nil && o.StartStatus == store.OccSnoozed {
+			sc.promptStart(u.TGID, taskID, day, next)
+		}
+	})
+	return next, nil
+}
+
+// SkipStart marks the occurrence as not done; no run is recorded.
+func (sc *Scheduler) SkipStart(u store.User, taskID int64, day string) error {
+	return sc.St.SetStartStatus(u.ID, taskID, day, store.OccSkipped, time.Time{})
+}
+
+// ConfirmFinish closes the run at the given (actual) finish time.
+func (sc *Scheduler) ConfirmFinish(u store.User, taskID int64, day string, at time.Time) error {
+	if err := sc.St.EndRun(u.ID, taskID, at.UTC()); err != nil {
+		return err
+	}
+	return sc.St.SetEndStatus(u.ID, taskID, day, store.OccConfirmed, time.Time{})
+}
+
+// ExtendFinish keeps the run open and repeats the finish prompt after
+// ExtendStep; it returns when the prompt will come.
+func (sc *Scheduler) ExtendFinish(u store.User, taskID int64, day string) (time.Time, error) {
+	next := sc.Clock.Now().Add(ExtendStep)
+	if err := sc.St.SetEndStatus(u.ID, taskID, day, store.OccExtended, time.Time{}); err != nil {
+		return next, err
+	}
+	sc.at(u, next, func() { sc.promptFinish(u.TGID, taskID, day, next) })
+	return next, nil
+}
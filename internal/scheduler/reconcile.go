@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/notify"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// plannedEnd returns when a run that began at start should have finished:
+// the end of the occurrence containing start, or start plus the task's
+// planned duration if the schedule no longer matches the run.
+func plannedEnd(t store.Task, start time.Time) time.Time {
+	for _, d := range []int{0, -1} {
+		s, e, ok := occurrence(t, timeutil.AddDays(start, d))
+		if ok && !start.Before(s) && start.Before(e) {
+			return e
+		}
+	}
+	dur := time.Duration(t.EndH*60+t.EndM-t.StartH*60-t.StartM) * time.Minute
+	if t.Overnight() {
+		dur += 24 * time.Hour
+	}
+	return start.Add(dur)
+}
+
+// Reconcile brings task_runs in line with the schedule after a restart:
+// runs whose finish was missed are closed at the planned end, and tasks that
+// should be in progress right now get a run opened at their planned start
+// (unless the user skipped them, the day is a holiday or the user confirms
+// starts manually).
+// With NotifyMissed set, the user is told about every missed event.
+func (sc *Scheduler) Reconcile(u store.User) error {
+	loc, err := time.LoadLocation(u.TZ)
+	if err != nil {
+		return err
+	}
+	now := sc.Clock.Now().In(loc)
+
+	all, err := sc.St.ListTasks(u.ID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]store.Task, len(all))
+	for _, t := range all {
+		byID[t.ID] = t
+	}
+
+	runs, err := sc.St.OpenRuns(u.ID)
+	if err != nil {
+		return err
+	}
+	open := map[int64]bool{}
+	for _, r := range runs {
+		// Manual timer runs are not tied to the schedule; see RestoreTimers.
+		if r.Source == store.RunTimer || r.TaskID == nil {
+			continue
+		}
+		t, ok := byID[*r.TaskID]
+		if !ok {
+			continue
+		}
+		end := plannedEnd(t, time.Unix(r.StartTs, 0).In(loc))
+		if t.Enabled && end.After(now) {
+			open[t.ID] = true
+			continue
+		}
+		if end.After(now) {
+			end = now
+		}
+		if _, err := sc.St.CloseRun(r.ID, end.UTC()); err != nil {
+			return err
+		}
+		sc.notifyMissed(u, t, notify.KindFinish, end, fmt.Sprintf("✅Финиш задачи: %s (пропущен в %s, бот был недоступен)", t.Title, end.Format("15:04")))
+	}
+
+	holidays, err := sc.St.GetHolidays(u.ID)
+	if err != nil {
+		return err
+	}
+	for _, t := range all {
+		if !t.Enabled || open[t.ID] {
+			continue
+		}
+		for _, d := range []int{0, -1} {
+			start, end, ok := occurrence(t, timeutil.AddDays(now, d))
+			if !ok || start.After(now) || !end.After(now) || holidays.Skips(t.ID, start) {
+				continue
+			}
+			if u.ConfirmPolicy == store.ConfirmManual {
+				break
+			}
+			if o, err := sc.St.GetOccurrence(u.ID, t.ID, OccurrenceDay(start)); err != nil || o.StartStatus == store.OccSkipped {
+				break
+			}
+			if err := sc.St.StartRun(u.ID, t.ID, start.UTC()); errors.Is(err, store.ErrRunOpen) {
+				break // a manual timer is running
+			} else if err != nil {
+				return err
+			}
+			sc.notifyMissed(u, t, notify.KindStart, start, fmt.Sprintf("🔔Старт задачи: %s (пропущен в %s, бот был недоступен)", t.Title, start.Format("15:04")))
+			break
+		}
+	}
+	return nil
+}
+
+// notifyMissed reports a start or finish of t that happened at at while the
+// bot was down. The event carries no occurrence day, so Telegram sends it
+// without answer buttons.
+func (sc *Scheduler) notifyMissed(u store.User, t store.Task, kind string, at time.Time, text string) {
+	if !sc.NotifyMissed {
+		return
+	}
+	sc.notify(sc.taskEvent(u, t, kind, "", at, text))
+}
@@ -2,87 +2,132 @@ package scheduler
 
 import (
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
 	"github.com/jmoiron/sqlx"
-	"gopkg.in/telebot.v3"
-
+	"github.com/okpulse/telegram-schedule-bot/internal/notify"
 	"github.com/okpulse/telegram-schedule-bot/internal/store"
 	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
 )
 
 type Scheduler struct {
-	S   gocron.Scheduler
-	Bot *telebot.Bot
-	DB  *sqlx.DB
-	St  *store.Store
+	S gocron.Scheduler
+	// Notifier delivers prompts, reminders and notices to users.
+	Notifier notify.Notifier
+	DB       *sqlx.DB
+	St       *store.Store
+	// Clock is the store's clock; the gocron scheduler runs on it too.
+	Clock timeutil.Clock
+
+	// NotifyMissed makes Reconcile tell users about start/finish events that
+	// were missed while the bot was down.
+	NotifyMissed bool
+	// TimerMax stops manual timers that run longer than this.
+	TimerMax time.Duration
 }
 
-func New(bot *telebot.Bot, st *store.Store) *Scheduler {
-	s, err := gocron.NewScheduler(gocron.WithLocation(time.UTC))
+// New starts a scheduler on the store's clock; opts are passed on to gocron.
+func New(n notify.Notifier, st *store.Store, opts ...gocron.SchedulerOption) *Scheduler {
+	opts = append([]gocron.SchedulerOption{gocron.WithLocation(time.UTC), gocron.WithClock(st.Clock)}, opts...)
+	s, err := gocron.NewScheduler(opts...)
 	if err != nil {
 		panic(err)
 	}
 	s.Start()
-	return &Scheduler{S: s, Bot: bot, DB: st.DB, St: st}
+	sc := &Scheduler{S: s, Notifier: n, DB: st.DB, St: st, Clock: st.Clock}
+	// Past dated tasks are archived for every user, with control on or off;
+	// hourly, so that each time zone's midnight is caught soon after.
+	_, _ = s.NewJob(gocron.DurationJob(time.Hour), gocron.NewTask(sc.ArchivePast))
+	return sc
+}
+
+// ArchivePast archives the past one-off tasks and finished recurrences of
+// all users.
+func (sc *Scheduler) ArchivePast() {
+	users, err := sc.St.ListUsers()
+	if err != nil {
+		log.Println("archive past tasks:", err)
+		return
+	}
+	for _, u := range users {
+		loc, err := time.LoadLocation(u.TZ)
+		if err != nil {
+			continue
+		}
+		if _, err := sc.St.ArchivePastTasks(u.ID, sc.Clock.Now().In(loc)); err != nil {
+			log.Printf("archive past tasks of user %d: %v", u.ID, err)
+		}
+	}
+}
+
+// notify delivers an event; failures are only logged, the schedule goes on.
+func (sc *Scheduler) notify(e notify.Event) {
+	if err := sc.Notifier.Notify(e); err != nil {
+		log.Printf("notify %s user %d: %v", e.Kind, e.UserID, err)
+	}
+}
+
+// taskEvent is an event about an occurrence of t.
+func (sc *Scheduler) taskEvent(u store.User, t store.Task, kind, day string, at time.Time, text string) notify.Event {
+	e := notify.ForUser(u, kind, at, text)
+	e.TaskID, e.Title, e.Day = t.ID, t.Title, day
+	return e
 }
 
 func (sc *Scheduler) userTag(userID int64) string { return fmt.Sprintf("user:%d", userID) }
 
+// ClearUser removes all of the user's jobs, including pending prompts.
 func (sc *Scheduler) ClearUser(userID int64) {
 	sc.S.RemoveByTags(sc.userTag(userID))
+	sc.S.RemoveByTags(sc.promptTag(userID))
 }
 
 func (sc *Scheduler) ScheduleAllForUser(u store.User) error {
-	sc.ClearUser(u.ID)
+	// Prompts already sent (snoozes, answer timeouts) stay scheduled.
+	sc.S.RemoveByTags(sc.userTag(u.ID))
+	loc, err := time.LoadLocation(u.TZ)
+	if err != nil {
+		return err
+	}
+	now := sc.Clock.Now().In(loc)
+	// one-off tasks whose date has passed go to the archive
+	if _, err := sc.St.ArchivePastTasks(u.ID, now); err != nil {
+		return err
+	}
 	tasks, err := sc.St.GetTasksForUser(u.ID)
 	if err != nil {
 		return err
 	}
-
-	loc, err := time.LoadLocation(u.TZ)
+	holidays, err := sc.St.GetHolidays(u.ID)
 	if err != nil {
 		return err
 	}
-	now := time.Now().In(loc)
-	weekdayBit := timeutil.WeekdayBit(now.Weekday())
 
+	next, nextErr := timeutil.NextLocalMidnightPlus(sc.Clock, u.TZ, 5)
 	for _, t := range tasks {
-		if (t.DaysMask & weekdayBit) == 0 {
-			continue
-		}
-		startLocal, _ := timeutil.LocalDateTime(u.TZ, t.StartH, t.StartM, 0)
-		endLocal, _ := timeutil.LocalDateTime(u.TZ, t.EndH, t.EndM, 0)
-		if endLocal.Before(startLocal) {
-			continue
+		if start, end, ok := occurrence(t, now); ok && !holidays.Skips(t.ID, start) {
+			sc.scheduleOccurrence(u, t, start, end, now)
 		}
-
-		if startLocal.After(now) {
-			startUTC := startLocal.UTC()
-			_, _ = sc.S.NewJob(
-				gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(startUTC)),
-				gocron.NewTask(func(chatID int64, userID, taskID int64, title string) {
-					_ = sc.St.StartRun(userID, taskID, time.Now().UTC())
-					sc.Bot.Send(&telebot.Chat{ID: chatID}, "🔔Старт задачи: "+title)
-				}, u.TGID, u.ID, t.ID, t.Title),
-				gocron.WithTags(sc.userTag(u.ID)),
-			)
+		// An overnight task that started yesterday still has its finish ahead.
+		if t.Overnight() {
+			if start, end, ok := occurrence(t, timeutil.AddDays(now, -1)); ok && !holidays.Skips(t.ID, start) {
+				sc.scheduleOccurrence(u, t, start, end, now)
+			}
 		}
-		if endLocal.After(now) {
-			endUTC := endLocal.UTC()
-			_, _ = sc.S.NewJob(
-				gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(endUTC)),
-				gocron.NewTask(func(chatID int64, userID, taskID int64, title string) {
-					_ = sc.St.EndRun(userID, taskID, time.Now().UTC())
-					sc.Bot.Send(&telebot.Chat{ID: chatID}, "✅Финиш задачи: "+title)
-				}, u.TGID, u.ID, t.ID, t.Title),
-				gocron.WithTags(sc.userTag(u.ID)),
-			)
+		// Reminders of the coming days that are due before the next
+		// re-plan: leads go up to a day, so the day after tomorrow too.
+		if nextErr == nil {
+			for d := 1; d <= 2; d++ {
+				if start, end, ok := occurrence(t, timeutil.AddDays(now, d)); ok && !holidays.Skips(t.ID, start) {
+					sc.scheduleReminders(u, t, start, end, now, next)
+				}
+			}
 		}
 	}
 
-	if next, err := timeutil.NextLocalMidnightPlus(u.TZ, 5); err == nil {
+	if nextErr == nil {
 		_, _ = sc.S.NewJob(
 			gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(next)),
 			gocron.NewTask(func(userTGID int64) {
@@ -97,12 +142,80 @@ func (sc *Scheduler) ScheduleAllForUser(u store.User) error {
 	return nil
 }
 
+// occurrence returns the planned window of the task occurrence that starts on
+// the calendar day of day. Overnight tasks finish on the next calendar day.
+// ok is false when the task does not run that day.
+func occurrence(t store.Task, day time.Time) (start, end time.Time, ok bool) {
+	if !t.RunsOn(day) {
+		return time.Time{}, time.Time{}, false
+	}
+	endOffset := 0
+	if t.Overnight() {
+		endOffset = 1
+	}
+	start = timeutil.DateTimeOn(day, t.StartH, t.StartM, 0)
+	end = timeutil.DateTimeOn(day, t.EndH, t.EndM, endOffset)
+	return start, end, true
+}
+
+// scheduleOccurrence plans the start and finish jobs of one task occurrence,
+// skipping events that are already in the past.
+func (sc *Scheduler) scheduleOccurrence(u store.User, t store.Task, startLocal, endLocal, now time.Time) {
+	sc.scheduleReminders(u, t, startLocal, endLocal, now, time.Time{})
+	day := OccurrenceDay(startLocal)
+	if startLocal.After(now) {
+		startUTC := startLocal.UTC()
+		_, _ = sc.S.NewJob(
+			gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(startUTC)),
+			gocron.NewTask(sc.promptStart, u.TGID, t.ID, day, startUTC),
+			gocron.WithTags(sc.userTag(u.ID)),
+		)
+	}
+	if endLocal.After(now) {
+		endUTC := endLocal.UTC()
+		_, _ = sc.S.NewJob(
+			gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(endUTC)),
+			gocron.NewTask(sc.promptFinish, u.TGID, t.ID, day, endUTC),
+			gocron.WithTags(sc.userTag(u.ID)),
+		)
+	}
+}
+
+// scheduleReminders plans the reminders of one task occurrence that are
+// between now and until (no limit if zero).
+func (sc *Scheduler) scheduleReminders(u store.User, t store.Task, startLocal, endLocal, now, until time.Time) {
+	startLead, endLead := t.ReminderLeads(u)
+	if at := startLocal.Add(-time.Duration(startLead) * time.Minute); startLead > 0 && (until.IsZero() || at.Before(until)) {
+		sc.scheduleReminder(u, t, startLocal, at, now, fmt.Sprintf("⏰Через %d мин начнётся: %s", startLead, t.Title))
+	}
+	if at := endLocal.Add(-time.Duration(endLead) * time.Minute); endLead > 0 && (until.IsZero() || at.Before(until)) {
+		sc.scheduleReminder(u, t, startLocal, at, now, fmt.Sprintf("⏰Через %d мин заканчивается: %s", endLead, t.Title))
+	}
+}
+
+// scheduleReminder plans a one-off reminder about the occurrence of t that
+// starts at start, unless its time has already passed.
+func (sc *Scheduler) scheduleReminder(u store.User, t store.Task, start, at, now time.Time, text string) {
+	if !at.After(now) {
+		return
+	}
+	e := sc.taskEvent(u, t, notify.KindReminder, OccurrenceDay(start), at, text)
+	_, _ = sc.S.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(at.UTC())),
+		gocron.NewTask(sc.notify, e),
+		gocron.WithTags(sc.userTag(u.ID)),
+	)
+}
+
 func (sc *Scheduler) RescheduleEnabledUsers() error {
 	users, err := sc.St.UsersWithControlEnabled()
 	if err != nil {
 		return err
 	}
 	for _, u := range users {
+		if err := sc.Reconcile(u); err != nil {
+			return err
+		}
 		if err := sc.ScheduleAllForUser(u); err != nil {
 			return err
 		}
@@ -0,0 +1,296 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/notify"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// runUntil moves the fake clock from job to job up to end. Each step waits
+// until the jobs due at that time have run, so the follow-ups they plan are
+// in place before the clock moves on.
+func runUntil(t *testing.T, sc *Scheduler, fc *clockwork.FakeClock, ran <-chan struct{}, end time.Time) {
+	t.Helper()
+	for fc.Now().Before(end) {
+		next, due := end, 0
+		for _, j := range sc.S.Jobs() {
+			// a job that has just run still lists its run at now
+			runs, _ := j.NextRuns(2)
+			for _, at := range runs {
+				if at.After(fc.Now()) {
+					if at.Before(next) {
+						next, due = at, 0
+					}
+					if at.Equal(next) {
+						due++
+					}
+					break
+				}
+			}
+		}
+		fc.Advance(next.Sub(fc.Now()))
+		for ; due > 0; due-- {
+			select {
+			case <-ran:
+			case <-time.After(10 * time.Second):
+				t.Fatalf("jobs due at %s did not run", next)
+			}
+		}
+	}
+}
+
+// newTestScheduler returns a scheduler on a fake clock together with a
+// channel that gets a value each time one of its jobs has run.
+func newTestScheduler(t *testing.T, n notify.Notifier, start time.Time) (*Scheduler, *clockwork.FakeClock, <-chan struct{}) {
+	t.Helper()
+	st, err := store.Open(filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.DB.Close() })
+	fc := clockwork.NewFakeClockAt(start)
+	st.Clock = fc
+	ran := make(chan struct{}, 100)
+	done := func(uuid.UUID, string) { ran <- struct{}{} }
+	sc := New(n, st, gocron.WithGlobalJobOptions(gocron.WithEventListeners(
+		gocron.AfterJobRuns(done),
+		gocron.AfterJobRunsWithError(func(id uuid.UUID, name string, _ error) { done(id, name) }),
+	)))
+	t.Cleanup(func() { sc.S.Shutdown() })
+	return sc, fc, ran
+}
+
+// weekUser sets up a Kyiv user with a daily task, a Friday overnight task
+// and a Sunday task at a time that happens twice when summer time ends,
+// plus a day off on Wednesday.
+func weekUser(t *testing.T, sc *Scheduler) (store.User, map[string]int64) {
+	t.Helper()
+	st := sc.St
+	u, err := st.GetOrCreateUser(42, "Europe/Kyiv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.UpdateUserReminders(u.ID, 10, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetControl(u.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	tasks := []store.Task{
+		{Title: "Работа", StartH: 9, EndH: 10, DaysMask: timeutil.MaskDaily()},
+		{Title: "Смена", StartH: 22, EndH: 2, DaysMask: timeutil.BitFri},
+		// 03:30 happens twice on 2026-10-25; the first one counts
+		{Title: "Ночь", StartH: 3, StartM: 30, EndH: 4, DaysMask: timeutil.BitSun},
+	}
+	ids := map[string]int64{}
+	for _, task := range tasks {
+		task.UserID = u.ID
+		if ids[task.Title], err = st.CreateTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.AddHoliday(store.Holiday{UserID: u.ID, Day: "2026-10-21", Title: "Выходной"}); err != nil {
+		t.Fatal(err)
+	}
+	if u, err = st.GetUserByID(u.ID); err != nil {
+		t.Fatal(err)
+	}
+	return u, ids
+}
+
+// utc parses a UTC date and time.
+func utc(day, hm string) time.Time {
+	at, err := time.Parse("2006-01-02 15:04", day+" "+hm)
+	if err != nil {
+		panic(err)
+	}
+	return at
+}
+
+// checkWeekRuns compares the task_runs of the week with the plan: the
+// Wednesday day off has none, the overnight run ends on Saturday and the
+// Sunday night run lasts 90 minutes across the clock change.
+func checkWeekRuns(t *testing.T, st *store.Store, u store.User, ids map[string]int64, to time.Time) {
+	t.Helper()
+	runs, err := st.ListRuns(u.ID, time.Unix(0, 0), to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type span struct {
+		task       int64
+		start, end time.Time
+	}
+	var want []span
+	for _, day := range []string{"2026-10-19", "2026-10-20", "2026-10-22", "2026-10-23"} {
+		want = append(want, span{ids["Работа"], utc(day, "06:00"), utc(day, "07:00")})
+	}
+	want = append(want,
+		span{ids["Смена"], utc("2026-10-23", "19:00"), utc("2026-10-23", "23:00")},
+		span{ids["Работа"], utc("2026-10-24", "06:00"), utc("2026-10-24", "07:00")},
+		span{ids["Ночь"], utc("2026-10-25", "00:30"), utc("2026-10-25", "02:00")},
+		span{ids["Работа"], utc("2026-10-25", "07:00"), utc("2026-10-25", "08:00")},
+	)
+	if len(runs) != len(want) {
+		t.Fatalf("got %d runs, want %d: %+v", len(runs), len(want), runs)
+	}
+	for i, r := range runs {
+		w := want[i]
+		if r.TaskID == nil || *r.TaskID != w.task || r.Source != store.RunSchedule ||
+			r.StartTs != w.start.Unix() || r.EndTs == nil || *r.EndTs != w.end.Unix() {
+			t.Errorf("run %d = %+v, want task %d %s–%s", i, r, w.task, w.start, w.end)
+		}
+	}
+}
+
+func TestSchedulerWeek(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	rec := &notify.Recorder{}
+	// Sunday noon; the week ends on the night Kyiv leaves summer time.
+	sc, fc, ran := newTestScheduler(t, rec, time.Date(2026, time.October, 18, 12, 0, 0, 0, kyiv))
+	u, ids := weekUser(t, sc)
+	if err := sc.ScheduleAllForUser(u); err != nil {
+		t.Fatal(err)
+	}
+
+	runUntil(t, sc, fc, ran, time.Date(2026, time.October, 26, 6, 0, 0, 0, kyiv))
+
+	type key struct{ kind, title, day string }
+	got := map[key][]time.Time{}
+	for _, e := range rec.Events() {
+		k := key{e.Kind, e.Title, e.Day}
+		got[k] = append(got[k], e.At)
+	}
+	want := map[key]time.Time{}
+	work := func(day, remind, start, finish string) {
+		want[key{notify.KindReminder, "Работа", day}] = utc(day, remind)
+		want[key{notify.KindStart, "Работа", day}] = utc(day, start)
+		want[key{notify.KindNotice, "Работа", day}] = utc(day, start)
+		want[key{notify.KindFinish, "Работа", day}] = utc(day, finish)
+	}
+	for _, day := range []string{"2026-10-19", "2026-10-20", "2026-10-22", "2026-10-23", "2026-10-24"} {
+		work(day, "05:50", "06:00", "07:00")
+	}
+	// winter time from the 25th
+	work("2026-10-25", "06:50", "07:00", "08:00")
+	// overnight: starts Friday, finishes Saturday
+	want[key{notify.KindReminder, "Смена", "2026-10-23"}] = utc("2026-10-23", "18:50")
+	want[key{notify.KindStart, "Смена", "2026-10-23"}] = utc("2026-10-23", "19:00")
+	want[key{notify.KindNotice, "Смена", "2026-10-23"}] = utc("2026-10-23", "19:00")
+	want[key{notify.KindFinish, "Смена", "2026-10-23"}] = utc("2026-10-23", "23:00")
+	// DST night: 03:30 EEST to 04:00 EET
+	want[key{notify.KindReminder, "Ночь", "2026-10-25"}] = utc("2026-10-25", "00:20")
+	want[key{notify.KindStart, "Ночь", "2026-10-25"}] = utc("2026-10-25", "00:30")
+	want[key{notify.KindNotice, "Ночь", "2026-10-25"}] = utc("2026-10-25", "00:30")
+	want[key{notify.KindFinish, "Ночь", "2026-10-25"}] = utc("2026-10-25", "02:00")
+
+	for k, at := range want {
+		if ats := got[k]; len(ats) != 1 || !ats[0].Equal(at) {
+			t.Errorf("%s %q %s: got %v, want once at %s", k.kind, k.title, k.day, ats, at)
+		}
+	}
+	for k, ats := range got {
+		if _, ok := want[k]; !ok {
+			t.Errorf("unexpected %s %q %s at %v", k.kind, k.title, k.day, ats)
+		}
+	}
+
+	checkWeekRuns(t, sc.St, u, ids, fc.Now())
+}
+
+func TestStaleStartAnswers(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	rec := &notify.Recorder{}
+	sc, fc, ran := newTestScheduler(t, rec, time.Date(2026, time.October, 19, 8, 0, 0, 0, kyiv))
+	u, ids := weekUser(t, sc)
+	if err := sc.ScheduleAllForUser(u); err != nil {
+		t.Fatal(err)
+	}
+	work, shift := ids["Работа"], ids["Смена"]
+
+	// the 09:00 prompt is out: answers are taken
+	runUntil(t, sc, fc, ran, time.Date(2026, time.October, 19, 9, 5, 0, 0, kyiv))
+	if _, err := sc.SnoozeStart(u, work, "2026-10-19"); err != nil {
+		t.Fatalf("snooze of a pending start: %v", err)
+	}
+	if err := sc.ConfirmStart(u, work, "2026-10-19", fc.Now()); err != nil {
+		t.Fatalf("confirm of a snoozed start: %v", err)
+	}
+	// settled: a second press does nothing
+	if err := sc.ConfirmStart(u, work, "2026-10-19", fc.Now()); !errors.Is(err, ErrStartSettled) {
+		t.Errorf("confirm of a confirmed start = %v, want ErrStartSettled", err)
+	}
+	if _, err := sc.SnoozeStart(u, work, "2026-10-19"); !errors.Is(err, ErrStartSettled) {
+		t.Errorf("snooze of a confirmed start = %v, want ErrStartSettled", err)
+	}
+
+	// the snoozed prompt is not repeated after the answer
+	runUntil(t, sc, fc, ran, time.Date(2026, time.October, 19, 9, 30, 0, 0, kyiv))
+	for _, e := range rec.Events() {
+		if e.Kind == notify.KindStart && e.TaskID == work && e.Day == "2026-10-19" && !e.At.Equal(utc("2026-10-19", "06:00")) {
+			t.Errorf("start prompt repeated at %s after it was answered", e.At)
+		}
+	}
+
+	// Friday's overnight start may be answered after midnight, not once the
+	// shift is over; the jobs are cleared so that nothing settles the prompt
+	// while the clock jumps.
+	runUntil(t, sc, fc, ran, time.Date(2026, time.October, 23, 22, 5, 0, 0, kyiv))
+	sc.ClearUser(u.ID)
+	fc.Advance(3 * time.Hour) // Saturday 01:05, the shift runs until 02:00
+	if _, err := sc.SnoozeStart(u, shift, "2026-10-23"); err != nil {
+		t.Errorf("snooze of an overnight start after midnight: %v", err)
+	}
+	fc.Advance(time.Hour)
+	if err := sc.ConfirmStart(u, shift, "2026-10-23", fc.Now()); !errors.Is(err, ErrStartSettled) {
+		t.Errorf("confirm after the overnight occurrence = %v, want ErrStartSettled", err)
+	}
+
+	runs, err := sc.St.ListRuns(u.ID, time.Unix(0, 0), fc.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range runs {
+		if r.TaskID != nil && *r.TaskID == shift {
+			t.Errorf("stale answer opened a run: %+v", r)
+		}
+	}
+}
+
+func TestAdherenceUnknownAge(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	now := time.Date(2026, time.October, 22, 12, 0, 0, 0, kyiv)
+	r := timeutil.PeriodRange(timeutil.PeriodAll, now)
+	legacy := store.Task{ID: 1, Title: "Старая", StartH: 9, EndH: 10, DaysMask: timeutil.MaskDaily(), Enabled: true}
+	ran := store.Task{ID: 2, Title: "Выполнялась", StartH: 9, EndH: 10, DaysMask: timeutil.MaskDaily(), Enabled: true}
+	start, end := time.Date(2026, time.October, 20, 9, 0, 0, 0, kyiv).Unix(), time.Date(2026, time.October, 20, 10, 0, 0, 0, kyiv).Unix()
+	taskID := ran.ID
+	runs := []store.TaskRun{{TaskID: &taskID, Source: store.RunSchedule, StartTs: start, EndTs: &end}}
+	firstRuns := map[int64]int64{ran.ID: start}
+
+	rows := adherence([]store.Task{legacy, ran}, runs, firstRuns, store.NewHolidays(nil), r, now)
+	if len(rows) != 1 || rows[0].TaskID != ran.ID {
+		t.Fatalf("rows = %+v, want only the task that was run", rows)
+	}
+	// planned from its first run: the 20th, 21st and 22nd
+	if got := rows[0]; got.Occurrences != 3 || got.Missed != 2 {
+		t.Errorf("occurrences = %d, missed = %d, want 3 and 2", got.Occurrences, got.Missed)
+	}
+}
@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/okpulse/telegram-schedule-bot/internal/notify"
+	"github.com/okpulse/telegram-schedule-bot/internal/store"
+)
+
+// DefaultTimerMax is used when Scheduler.TimerMax is not set.
+const DefaultTimerMax = 8 * time.Hour
+
+// ErrNoTimer is returned by StopTimer when no manual run is open.
+var ErrNoTimer = errors.New("no running timer")
+
+func (sc *Scheduler) timerTag(userID int64) string { return fmt.Sprintf("timer:%d", userID) }
+
+func (sc *Scheduler) timerMax() time.Duration {
+	if sc.TimerMax > 0 {
+		return sc.TimerMax
+	}
+	return DefaultTimerMax
+}
+
+// StartTimer opens a manual run (for a task or a free-form title) and plans
+// its automatic stop after TimerMax. Timer jobs do not depend on /run.
+func (sc *Scheduler) StartTimer(u store.User, taskID *int64, title string) (time.Time, error) {
+	start := sc.Clock.Now().UTC()
+	runID, err := sc.St.StartTimer(u.ID, taskID, title, start)
+	if err != nil {
+		return start, err
+	}
+	sc.scheduleTimerStop(u, runID, title, start)
+	return start, nil
+}
+
+// StopTimer closes the user's manual run and returns it with its end time.
+func (sc *Scheduler) StopTimer(u store.User) (store.ActiveRun, time.Time, error) {
+	runs, err := sc.St.ActiveRuns(u.ID)
+	if err != nil {
+		return store.ActiveRun{}, time.Time{}, err
+	}
+	for _, r := range runs {
+		if r.Source != store.RunTimer {
+			continue
+		}
+		end := sc.Clock.Now().UTC()
+		if _, err := sc.St.CloseRun(r.ID, end); err != nil {
+			return r, end, err
+		}
+		sc.S.RemoveByTags(sc.timerTag(u.ID))
+		return r, end, nil
+	}
+	return store.ActiveRun{}, time.Time{}, ErrNoTimer
+}
+
+func (sc *Scheduler) scheduleTimerStop(u store.User, runID int64, title string, start time.Time) {
+	limit := sc.timerMax()
+	stopAt := start.Add(limit)
+	_, _ = sc.S.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(stopAt)),
+		gocron.NewTask(func() {
+			if closed, err := sc.St.CloseRun(runID, stopAt); err != nil || !closed {
+				return
+			}
+			e := notify.ForUser(u, notify.KindNotice, stopAt, fmt.Sprintf("⏱ Таймер «%s» остановлен автоматически через %s.", title, limit))
+			e.Title = title
+			sc.notify(e)
+		}),
+		gocron.WithTags(sc.timerTag(u.ID)),
+	)
+}
+
+// RestoreTimers runs at startup: manual runs that exceeded TimerMax while the
+// bot was down are closed at start+TimerMax, the rest get their stop job back.
+func (sc *Scheduler) RestoreTimers() error {
+	runs, err := sc.St.OpenTimerRuns()
+	if err != nil {
+		return err
+	}
+	now := sc.Clock.Now().UTC()
+	for _, r := range runs {
+		start := time.Unix(r.StartTs, 0).UTC()
+		if stopAt := start.Add(sc.timerMax()); !stopAt.After(now) {
+			if _, err := sc.St.CloseRun(r.ID, stopAt); err != nil {
+				return err
+			}
+			continue
+		}
+		u, err := sc.St.GetUserByID(r.UserID)
+		if err != nil {
+			return err
+		}
+		sc.scheduleTimerStop(u, r.ID, r.DisplayTitle, start)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package store
+
+import "time"
+
+// Conversation is the persisted state of a multi-step dialog (e.g. the /add
+// wizard) for one Telegram user. Data is the flow's JSON-encoded payload.
+type Conversation struct {
+	TGID      int64  `db:"tg_id"`
+	State     string `db:"state"`
+	Data      string `db:"data"`
+	ExpiresAt int64  `db:"expires_at"`
+}
+
+// GetConversation returns sql.ErrNoRows if the user has no conversation.
+func (s *Store) GetConversation(tgID int64) (Conversation, error) {
+	var c Conversation
+	err := s.DB.Get(&c, "SELECT tg_id, state, data, expires_at FROM conversations WHERE tg_id = ?", tgID)
+	return c, err
+}
+
+func (s *Store) SaveConversation(c Conversation) error {
+	_, err := s.DB.Exec(`INSERT INTO conversations (tg_id, state, data, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(tg_id) DO UPDATE SET state = excluded.state, data = excluded.data, expires_at = excluded.expires_at`,
+		c.TGID, c.State, c.Data, c.ExpiresAt)
+	return err
+}
+
+func (s *Store) DeleteConversation(tgID int64) error {
+	_, err := s.DB.Exec("DELETE FROM conversations WHERE tg_id = ?", tgID)
+	return err
+}
+
+// DeleteExpiredConversations removes conversations that expired before now.
+func (s *Store) DeleteExpiredConversations(now time.Time) (int64, error) {
+	res, err := s.DB.Exec("DELETE FROM conversations WHERE expires_at <= ?", now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// Holiday is an exception date on which tasks do not run: all of the user's
+// tasks when TaskID is nil, one task otherwise. Day is a local date
+// (YYYY-MM-DD).
+type Holiday struct {
+	ID     int64  `db:"id"`
+	UserID int64  `db:"user_id"`
+	TaskID *int64 `db:"task_id"`
+	Day    string `db:"day"`
+	Title  string `db:"title"`
+}
+
+// Holidays answers whether an occurrence falls on an exception date.
+type Holidays struct {
+	all  map[string]bool
+	task map[int64]map[string]bool
+}
+
+// NewHolidays indexes a user's exception dates.
+func NewHolidays(list []Holiday) Holidays {
+	h := Holidays{all: map[string]bool{}, task: map[int64]map[string]bool{}}
+	for _, d := range list {
+		if d.TaskID == nil {
+			h.all[d.Day] = true
+			continue
+		}
+		if h.task[*d.TaskID] == nil {
+			h.task[*d.TaskID] = map[string]bool{}
+		}
+		h.task[*d.TaskID][d.Day] = true
+	}
+	return h
+}
+
+// Skips reports whether the occurrence of the task starting on the calendar
+// day of day is cancelled by a holiday or a skip date of the task.
+func (h Holidays) Skips(taskID int64, day time.Time) bool {
+	d := day.Format(timeutil.DateLayout)
+	return h.all[d] || h.task[taskID][d]
+}
+
+// ListHolidays returns the exception dates of the user (taskID nil) or of
+// one task from the local date from on, in date order.
+func (s *Store) ListHolidays(userID int64, taskID *int64, from string) ([]Holiday, error) {
+	var out []Holiday
+	err := s.DB.Select(&out, `SELECT id, user_id, task_id, day, title FROM holidays
+		WHERE user_id = ? AND IFNULL(task_id, 0) = ? AND day >= ? ORDER BY day`, userID, idOrZero(taskID), from)
+	return out, err
+}
+
+// GetHolidays returns all exception dates of the user, for both the user
+// and the tasks.
+func (s *Store) GetHolidays(userID int64) (Holidays, error) {
+	var list []Holiday
+	if err := s.DB.Select(&list, "SELECT id, user_id, task_id, day, title FROM holidays WHERE user_id = ?", userID); err != nil {
+		return Holidays{}, err
+	}
+	return NewHolidays(list), nil
+}
+
+// AddHoliday stores an exception date; added is false if it already
+// existed.
+func (s *Store) AddHoliday(h Holiday) (added bool, err error) {
+	res, err := s.DB.Exec("INSERT OR IGNORE INTO holidays (user_id, task_id, day, title) VALUES (?, ?, ?, ?)",
+		h.UserID, h.TaskID, h.Day, h.Title)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ToggleHoliday adds the exception date, or removes it if it exists; on
+// reports whether it is set afterwards.
+func (s *Store) ToggleHoliday(userID int64, taskID *int64, day string) (on bool, err error) {
+	res, err := s.DB.Exec("DELETE FROM holidays WHERE user_id = ? AND IFNULL(task_id, 0) = ? AND day = ?", userID, idOrZero(taskID), day)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return false, err
+	}
+	return s.AddHoliday(Holiday{UserID: userID, TaskID: taskID, Day: day})
+}
+
+// ClearHolidays removes the user's (not the tasks') exception dates from
+// the local date from on.
+func (s *Store) ClearHolidays(userID int64, from string) (int64, error) {
+	res, err := s.DB.Exec("DELETE FROM holidays WHERE user_id = ? AND task_id IS NULL AND day >= ?", userID, from)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func idOrZero(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
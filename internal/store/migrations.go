@@ -1,10 +1,16 @@
 package store
 
 import (
+	"crypto/sha256"
+	"database/sql"
 	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -12,27 +18,221 @@ import (
 //go:embed migrations/*.sql
 var migrationsFS embed.FS
 
-func runMigrations(db *sqlx.DB) error {
+// Migration is one embedded schema change. Up comes from NNN_name.sql and
+// Down from the optional NNN_name.down.sql next to it.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus describes a known migration and whether it is applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type appliedMigration struct {
+	Version   int    `db:"version"`
+	Name      string `db:"name"`
+	Checksum  string `db:"checksum"`
+	AppliedAt int64  `db:"applied_at"`
+}
+
+const schemaMigrationsDDL = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    checksum TEXT NOT NULL,
+    applied_at INTEGER NOT NULL
+)`
+
+func loadMigrations() ([]Migration, error) {
 	entries, err := migrationsFS.ReadDir("migrations")
 	if err != nil {
-		return fmt.Errorf("read migrations dir: %w", err)
+		return nil, fmt.Errorf("read migrations dir: %w", err)
 	}
-	names := []string{}
+	byVersion := map[int]*Migration{}
 	for _, e := range entries {
 		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
 			continue
 		}
-		names = append(names, e.Name())
-	}
-	sort.Strings(names)
-	for _, name := range names {
-		b, err := migrationsFS.ReadFile("migrations/" + name)
+		base := strings.TrimSuffix(e.Name(), ".sql")
+		down := strings.HasSuffix(base, ".down")
+		base = strings.TrimSuffix(base, ".down")
+		prefix, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected NNN_name.sql", e.Name())
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: bad version: %w", e.Name(), err)
+		}
+		b, err := migrationsFS.ReadFile("migrations/" + e.Name())
 		if err != nil {
-			return fmt.Errorf("read migration %s: %w", name, err)
+			return nil, fmt.Errorf("read migration %s: %w", e.Name(), err)
 		}
-		if _, err := db.Exec(string(b)); err != nil {
-			return fmt.Errorf("apply migration %s: %w", name, err)
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if down {
+			m.Down = string(b)
+			continue
 		}
+		if m.Up != "" {
+			return nil, fmt.Errorf("migration %s: duplicate version %d", e.Name(), version)
+		}
+		sum := sha256.Sum256(b)
+		m.Up = string(b)
+		m.Checksum = hex.EncodeToString(sum[:])
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %03d_%s: down file without up file", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+func appliedMigrations(db *sqlx.DB) (map[int]appliedMigration, error) {
+	if _, err := db.Exec(schemaMigrationsDDL); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	var rows []appliedMigration
+	if err := db.Select(&rows, "SELECT version, name, checksum, applied_at FROM schema_migrations"); err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	out := make(map[int]appliedMigration, len(rows))
+	for _, r := range rows {
+		out[r.Version] = r
+	}
+	return out, nil
+}
+
+// runMigrations applies every pending migration in version order, each in its
+// own transaction. It refuses to continue if an already applied migration was
+// edited after the fact.
+func runMigrations(db *sqlx.DB) error {
+	_, err := migrateUp(db)
+	return err
+}
+
+func migrateUp(db *sqlx.DB) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range migrations {
+		if a, ok := applied[m.Version]; ok && a.Checksum != m.Checksum {
+			return 0, fmt.Errorf("migration %03d_%s was modified after it was applied (checksum %s, expected %s)", m.Version, m.Name, m.Checksum, a.Checksum)
+		}
+	}
+	n := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func applyMigration(db *sqlx.DB, m Migration) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(m.Up); err != nil {
+		return fmt.Errorf("apply migration %03d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+		m.Version, m.Name, m.Checksum, time.Now().Unix()); err != nil {
+		return fmt.Errorf("record migration %03d_%s: %w", m.Version, m.Name, err)
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus lists all embedded migrations with their applied state.
+func (s *Store) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = time.Unix(a.AppliedAt, 0)
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// MigrateUp applies pending migrations and returns how many were applied.
+func (s *Store) MigrateUp() (int, error) {
+	return migrateUp(s.DB)
+}
+
+// RollbackMigration reverts the most recently applied migration using its
+// .down.sql file and returns it.
+func (s *Store) RollbackMigration() (Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return Migration{}, err
+	}
+	var last appliedMigration
+	err = s.DB.Get(&last, "SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version DESC LIMIT 1")
+	if errors.Is(err, sql.ErrNoRows) {
+		return Migration{}, errors.New("no applied migrations")
+	}
+	if err != nil {
+		return Migration{}, err
+	}
+	var m Migration
+	for _, cand := range migrations {
+		if cand.Version == last.Version {
+			m = cand
+		}
+	}
+	if m.Up == "" {
+		return Migration{}, fmt.Errorf("migration %03d_%s is not embedded in this build", last.Version, last.Name)
+	}
+	if m.Down == "" {
+		return m, fmt.Errorf("migration %03d_%s has no down file", m.Version, m.Name)
+	}
+
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return m, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(m.Down); err != nil {
+		return m, fmt.Errorf("roll back migration %03d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+		return m, err
 	}
-	return nil
+	return m, tx.Commit()
 }
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_task_runs_start;
+DROP INDEX IF EXISTS idx_task_runs_task;
+DROP INDEX IF EXISTS idx_task_runs_user;
+DROP TABLE IF EXISTS task_runs;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_conversations_expires;
+DROP TABLE IF EXISTS conversations;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS conversations (
+    tg_id INTEGER PRIMARY KEY,
+    state TEXT NOT NULL,
+    data TEXT NOT NULL DEFAULT '{}',
+    expires_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversations_expires ON conversations(expires_at);
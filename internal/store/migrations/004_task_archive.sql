@@ -0,0 +1,3 @@
+ALTER TABLE tasks ADD COLUMN archived_at INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_archived ON tasks(user_id, archived_at);
@@ -0,0 +1,4 @@
+ALTER TABLE tasks DROP COLUMN remind_end;
+ALTER TABLE tasks DROP COLUMN remind_start;
+ALTER TABLE users DROP COLUMN remind_end;
+ALTER TABLE users DROP COLUMN remind_start;
@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN remind_start INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN remind_end INTEGER NOT NULL DEFAULT 0;
+
+-- NULL means "use the user's default"
+ALTER TABLE tasks ADD COLUMN remind_start INTEGER;
+ALTER TABLE tasks ADD COLUMN remind_end INTEGER;
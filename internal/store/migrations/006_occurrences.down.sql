@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_occurrences_user_day;
+DROP TABLE IF EXISTS occurrences;
+ALTER TABLE users DROP COLUMN confirm_timeout;
+ALTER TABLE users DROP COLUMN confirm_policy;
@@ -0,0 +1,21 @@
+-- confirm_policy: 'auto' records unanswered starts by plan after
+-- confirm_timeout minutes, 'manual' leaves them untracked.
+ALTER TABLE users ADD COLUMN confirm_policy TEXT NOT NULL DEFAULT 'auto';
+ALTER TABLE users ADD COLUMN confirm_timeout INTEGER NOT NULL DEFAULT 15;
+
+-- One row per task occurrence (day = local start date, YYYY-MM-DD) with the
+-- user's response to its start and finish prompts.
+CREATE TABLE IF NOT EXISTS occurrences (
+    user_id INTEGER NOT NULL,
+    task_id INTEGER NOT NULL,
+    day TEXT NOT NULL,
+    start_status TEXT NOT NULL DEFAULT '',
+    start_prompt_at INTEGER,
+    end_status TEXT NOT NULL DEFAULT '',
+    end_prompt_at INTEGER,
+    PRIMARY KEY (task_id, day),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_occurrences_user_day ON occurrences(user_id, day);
@@ -0,0 +1,21 @@
+-- Free-form timer runs have no task and cannot be kept.
+CREATE TABLE task_runs_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    task_id INTEGER NOT NULL,
+    start_ts INTEGER NOT NULL,
+    end_ts INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+);
+
+INSERT INTO task_runs_old (id, user_id, task_id, start_ts, end_ts, created_at)
+    SELECT id, user_id, task_id, start_ts, end_ts, created_at FROM task_runs WHERE task_id IS NOT NULL;
+
+DROP TABLE task_runs;
+ALTER TABLE task_runs_old RENAME TO task_runs;
+
+CREATE INDEX IF NOT EXISTS idx_task_runs_user ON task_runs(user_id);
+CREATE INDEX IF NOT EXISTS idx_task_runs_task ON task_runs(task_id);
+CREATE INDEX IF NOT EXISTS idx_task_runs_start ON task_runs(start_ts);
@@ -0,0 +1,25 @@
+-- Manual (stopwatch) runs may have no task: task_id becomes nullable and
+-- free-form runs keep their own title. source is 'schedule' or 'timer'.
+CREATE TABLE task_runs_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    task_id INTEGER,
+    title TEXT,
+    source TEXT NOT NULL DEFAULT 'schedule',
+    start_ts INTEGER NOT NULL,
+    end_ts INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+);
+
+INSERT INTO task_runs_new (id, user_id, task_id, start_ts, end_ts, created_at)
+    SELECT id, user_id, task_id, start_ts, end_ts, created_at FROM task_runs;
+
+DROP TABLE task_runs;
+ALTER TABLE task_runs_new RENAME TO task_runs;
+
+CREATE INDEX IF NOT EXISTS idx_task_runs_user ON task_runs(user_id);
+CREATE INDEX IF NOT EXISTS idx_task_runs_task ON task_runs(task_id);
+CREATE INDEX IF NOT EXISTS idx_task_runs_start ON task_runs(start_ts);
+CREATE INDEX IF NOT EXISTS idx_task_runs_open ON task_runs(user_id, end_ts);
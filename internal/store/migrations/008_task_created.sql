@@ -0,0 +1,6 @@
+-- When the task was created, so that reports do not count occurrences
+-- planned before it existed. Existing tasks take the start of their first
+-- run; NULL means unknown.
+ALTER TABLE tasks ADD COLUMN created_at INTEGER;
+
+UPDATE tasks SET created_at = (SELECT MIN(start_ts) FROM task_runs WHERE task_runs.task_id = tasks.id);
@@ -0,0 +1,3 @@
+-- One-off tasks run only on on_date, a local calendar date (YYYY-MM-DD);
+-- NULL for tasks that repeat on days_mask.
+ALTER TABLE tasks ADD COLUMN on_date TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE tasks DROP COLUMN rrule_start;
+ALTER TABLE tasks DROP COLUMN rrule;
@@ -0,0 +1,4 @@
+-- Tasks with a recurrence rule (an RFC 5545 RRULE value) repeat by it,
+-- counted from rrule_start (YYYY-MM-DD), instead of by days_mask.
+ALTER TABLE tasks ADD COLUMN rrule TEXT;
+ALTER TABLE tasks ADD COLUMN rrule_start TEXT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_holidays_user_task_day;
+DROP TABLE IF EXISTS holidays;
@@ -0,0 +1,14 @@
+-- Exception dates (local YYYY-MM-DD) on which scheduled tasks do not run:
+-- a holiday for all of the user's tasks when task_id is NULL, a skip date
+-- of one task otherwise.
+CREATE TABLE IF NOT EXISTS holidays (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    task_id INTEGER,
+    day TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_holidays_user_task_day ON holidays(user_id, IFNULL(task_id, 0), day);
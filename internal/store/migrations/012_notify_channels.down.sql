@@ -0,0 +1 @@
+DROP TABLE IF EXISTS notify_channels;
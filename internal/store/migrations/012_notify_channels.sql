@@ -0,0 +1,9 @@
+-- Extra notification channels besides Telegram: kind is 'email' (target is
+-- the address), 'webhook' (target is the URL) or 'log'.
+CREATE TABLE IF NOT EXISTS notify_channels (
+    user_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    target TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (user_id, kind),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_outbox_status_next;
+DROP INDEX IF EXISTS idx_outbox_channel_key;
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,19 @@
+-- Outgoing notifications, one row per event and channel. Rows stay after
+-- delivery as the delivery log and are purged after a while.
+CREATE TABLE IF NOT EXISTS outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    channel TEXT NOT NULL,              -- 'telegram' or a notify_channels kind
+    target TEXT NOT NULL DEFAULT '',    -- address or URL of the channel
+    dedup_key TEXT,                     -- one row per key and channel; NULL = no dedup
+    event TEXT NOT NULL,                -- the event as JSON
+    status TEXT NOT NULL DEFAULT 'pending', -- pending, sent, failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_at INTEGER NOT NULL,           -- unix seconds of the next attempt
+    last_error TEXT,
+    created_at INTEGER NOT NULL,
+    sent_at INTEGER,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_channel_key ON outbox(channel, dedup_key);
+CREATE INDEX IF NOT EXISTS idx_outbox_status_next ON outbox(status, next_at);
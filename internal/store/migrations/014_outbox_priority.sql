@@ -0,0 +1,3 @@
+-- Delivery order of outbox messages: lower goes first (start/finish prompts
+-- before reminders, notices and digests).
+ALTER TABLE outbox ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE notify_channels DROP COLUMN code_sent_at;
+ALTER TABLE notify_channels DROP COLUMN code;
@@ -0,0 +1,5 @@
+-- An email channel is used only after the user proves they own the address:
+-- code is the confirmation code mailed to it (NULL once confirmed) and
+-- code_sent_at when it was sent.
+ALTER TABLE notify_channels ADD COLUMN code TEXT;
+ALTER TABLE notify_channels ADD COLUMN code_sent_at INTEGER;
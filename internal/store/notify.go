@@ -0,0 +1,68 @@
+package store
+
+import "time"
+
+// Notification channels a user can add to Telegram.
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelLog     = "log"
+)
+
+// NotifyChannel is an extra delivery channel of a user. Target is the email
+// address or webhook URL; empty for the log.
+type NotifyChannel struct {
+	UserID int64  `db:"user_id"`
+	Kind   string `db:"kind"`
+	Target string `db:"target"`
+	// Code is the confirmation code sent to an email address not yet
+	// confirmed; the channel is not used while it is set.
+	Code       *string `db:"code"`
+	CodeSentAt *int64  `db:"code_sent_at"`
+}
+
+// Confirmed reports whether the channel may be used.
+func (c NotifyChannel) Confirmed() bool { return c.Code == nil }
+
+// CodeSentSince reports whether a confirmation code went to the user's
+// channel after the given time.
+func (c NotifyChannel) CodeSentSince(t time.Time) bool {
+	return c.CodeSentAt != nil && *c.CodeSentAt > t.Unix()
+}
+
+// ListNotifyChannels returns the user's extra channels, unconfirmed ones
+// included.
+func (s *Store) ListNotifyChannels(userID int64) ([]NotifyChannel, error) {
+	var out []NotifyChannel
+	err := s.DB.Select(&out, "SELECT user_id, kind, target, code, code_sent_at FROM notify_channels WHERE user_id = ? ORDER BY kind", userID)
+	return out, err
+}
+
+// SetNotifyChannel adds the channel or replaces its target and code.
+func (s *Store) SetNotifyChannel(c NotifyChannel) error {
+	_, err := s.DB.Exec(`INSERT INTO notify_channels (user_id, kind, target, code, code_sent_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, kind) DO UPDATE SET target = excluded.target, code = excluded.code, code_sent_at = excluded.code_sent_at`,
+		c.UserID, c.Kind, c.Target, c.Code, c.CodeSentAt)
+	return err
+}
+
+// ConfirmNotifyChannel enables the channel if code matches. A wrong code
+// removes the unconfirmed channel, so every code gets a single guess.
+func (s *Store) ConfirmNotifyChannel(userID int64, kind, code string) (ok bool, err error) {
+	res, err := s.DB.Exec("UPDATE notify_channels SET code = NULL, code_sent_at = NULL WHERE user_id = ? AND kind = ? AND code = ?",
+		userID, kind, code)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return n > 0, err
+	}
+	_, err = s.DB.Exec("DELETE FROM notify_channels WHERE user_id = ? AND kind = ? AND code IS NOT NULL", userID, kind)
+	return false, err
+}
+
+// DeleteNotifyChannel removes one of the user's channels.
+func (s *Store) DeleteNotifyChannel(userID int64, kind string) error {
+	_, err := s.DB.Exec("DELETE FROM notify_channels WHERE user_id = ? AND kind = ?", userID, kind)
+	return err
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Occurrence response statuses.
+const (
+	OccPrompted  = "prompted"  // prompt sent, no answer yet
+	OccConfirmed = "confirmed" // the user answered
+	OccSnoozed   = "snoozed"   // start postponed
+	OccExtended  = "extended"  // the user is still working past the finish
+	OccSkipped   = "skipped"   // the user skipped the occurrence
+	OccAuto      = "auto"      // recorded by plan after the timeout
+)
+
+// Confirmation policies for unanswered start prompts.
+const (
+	ConfirmAuto   = "auto"
+	ConfirmManual = "manual"
+)
+
+// DefaultConfirmTimeout is the default wait (minutes) for an answer to a
+// start or finish prompt; it matches the column default.
+const DefaultConfirmTimeout = 15
+
+// Occurrence tracks the user's answers to the start and finish prompts of a
+// single task occurrence. Day is the local date of the occurrence start.
+type Occurrence struct {
+	UserID        int64  `db:"user_id"`
+	TaskID        int64  `db:"task_id"`
+	Day           string `db:"day"`
+	StartStatus   string `db:"start_status"`
+	StartPromptAt *int64 `db:"start_prompt_at"`
+	EndStatus     string `db:"end_status"`
+	EndPromptAt   *int64 `db:"end_prompt_at"`
+}
+
+// GetOccurrence returns the occurrence row, or an empty one (no statuses)
+// if nothing was recorded yet.
+func (s *Store) GetOccurrence(userID, taskID int64, day string) (Occurrence, error) {
+	o := Occurrence{UserID: userID, TaskID: taskID, Day: day}
+	err := s.DB.Get(&o, `SELECT user_id, task_id, day, start_status, start_prompt_at, end_status, end_prompt_at
+		FROM occurrences WHERE user_id = ? AND task_id = ? AND day = ?`, userID, taskID, day)
+	if errors.Is(err, sql.ErrNoRows) {
+		return o, nil
+	}
+	return o, err
+}
+
+// SetStartStatus records the start response; a zero promptAt keeps the
+// previous prompt time.
+func (s *Store) SetStartStatus(userID, taskID int64, day, status string, promptAt time.Time) error {
+	var at *int64
+	if !promptAt.IsZero() {
+		v := promptAt.Unix()
+		at = &v
+	}
+	_, err := s.DB.Exec(`INSERT INTO occurrences (user_id, task_id, day, start_status, start_prompt_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(task_id, day) DO UPDATE SET start_status = excluded.start_status,
+			start_prompt_at = COALESCE(excluded.start_prompt_at, occurrences.start_prompt_at)`,
+		userID, taskID, day, status, at)
+	return err
+}
+
+// SetEndStatus records the finish response; a zero promptAt keeps the
+// previous prompt time.
+func (s *Store) SetEndStatus(userID, taskID int64, day, status string, promptAt time.Time) error {
+	var at *int64
+	if !promptAt.IsZero() {
+		v := promptAt.Unix()
+		at = &v
+	}
+	_, err := s.DB.Exec(`INSERT INTO occurrences (user_id, task_id, day, end_status, end_prompt_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(task_id, day) DO UPDATE SET end_status = excluded.end_status,
+			end_prompt_at = COALESCE(excluded.end_prompt_at, occurrences.end_prompt_at)`,
+		userID, taskID, day, status, at)
+	return err
+}
+
+// UpdateUserConfirm sets how unanswered start prompts are handled.
+func (s *Store) UpdateUserConfirm(userID int64, policy string, timeoutMin int) error {
+	_, err := s.DB.Exec("UPDATE users SET confirm_policy = ?, confirm_timeout = ? WHERE id = ?", policy, timeoutMin, userID)
+	return err
+}
+
+// HasOpenRun reports whether the task has a scheduled run without an end.
+func (s *Store) HasOpenRun(userID, taskID int64) (bool, error) {
+	var n int
+	err := s.DB.Get(&n, "SELECT COUNT(1) FROM task_runs WHERE user_id = ? AND task_id = ? AND source = ? AND end_ts IS NULL", userID, taskID, RunSchedule)
+	return n > 0, err
+}
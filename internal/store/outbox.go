@@ -0,0 +1,176 @@
+package store
+
+import "time"
+
+// ChannelTelegram is the outbox channel of Telegram messages; the other
+// channels are the notify_channels kinds.
+const ChannelTelegram = "telegram"
+
+// Outbox message statuses.
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// OutboxMessage is one notification queued for one channel.
+type OutboxMessage struct {
+	ID        int64   `db:"id"`
+	UserID    int64   `db:"user_id"`
+	Channel   string  `db:"channel"`
+	Target    string  `db:"target"`
+	DedupKey  *string `db:"dedup_key"`
+	Event     string  `db:"event"`    // JSON
+	Priority  int     `db:"priority"` // lower goes first
+	Status    string  `db:"status"`
+	Attempts  int     `db:"attempts"`
+	NextAt    int64   `db:"next_at"`
+	LastError *string `db:"last_error"`
+	CreatedAt int64   `db:"created_at"`
+	SentAt    *int64  `db:"sent_at"`
+}
+
+const outboxColumns = "id, user_id, channel, target, dedup_key, event, priority, status, attempts, next_at, last_error, created_at, sent_at"
+
+// Enqueue adds a pending message due now. A message whose dedup key was
+// already queued for the channel is dropped; added reports which happened.
+func (s *Store) Enqueue(m OutboxMessage) (added bool, err error) {
+	now := s.Clock.Now().Unix()
+	res, err := s.DB.Exec(`INSERT OR IGNORE INTO outbox (user_id, channel, target, dedup_key, event, priority, status, next_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, m.UserID, m.Channel, m.Target, m.DedupKey, m.Event, m.Priority, OutboxPending, now, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DueOutbox returns up to limit pending messages whose time has come, by
+// priority and then oldest first: the Telegram ones, or those of all other
+// channels.
+func (s *Store) DueOutbox(now time.Time, limit int, telegram bool) ([]OutboxMessage, error) {
+	var out []OutboxMessage
+	err := s.DB.Select(&out, "SELECT "+outboxColumns+` FROM outbox
+		WHERE status = ? AND next_at <= ? AND (channel = ?) = ? ORDER BY priority, next_at, id LIMIT ?`,
+		OutboxPending, now.Unix(), ChannelTelegram, telegram, limit)
+	return out, err
+}
+
+// NextOutboxAt returns when the earliest pending message of Telegram (or of
+// the other channels) is due; ok is false if nothing is pending.
+func (s *Store) NextOutboxAt(telegram bool) (at time.Time, ok bool, err error) {
+	var next *int64
+	if err := s.DB.Get(&next, "SELECT MIN(next_at) FROM outbox WHERE status = ? AND (channel = ?) = ?",
+		OutboxPending, ChannelTelegram, telegram); err != nil || next == nil {
+		return time.Time{}, false, err
+	}
+	return time.Unix(*next, 0), true, nil
+}
+
+// ClaimOutbox leases a pending message to a sender until the given time,
+// so it is not picked up again while being sent; if the sender never
+// reports back, the message is due again when the lease ends.
+func (s *Store) ClaimOutbox(id int64, until time.Time) (bool, error) {
+	res, err := s.DB.Exec("UPDATE outbox SET next_at = ? WHERE id = ? AND status = ?", until.Unix(), id, OutboxPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// MarkSent records a delivered message.
+func (s *Store) MarkSent(id int64, at time.Time) error {
+	_, err := s.DB.Exec("UPDATE outbox SET status = ?, attempts = attempts + 1, sent_at = ?, last_error = NULL WHERE id = ?",
+		OutboxSent, at.Unix(), id)
+	return err
+}
+
+// MarkRetry records a failed attempt and when to try again.
+func (s *Store) MarkRetry(id int64, next time.Time, reason string) error {
+	_, err := s.DB.Exec("UPDATE outbox SET attempts = attempts + 1, next_at = ?, last_error = ? WHERE id = ?",
+		next.Unix(), reason, id)
+	return err
+}
+
+// MarkFailed gives up on a message.
+func (s *Store) MarkFailed(id int64, reason string) error {
+	_, err := s.DB.Exec("UPDATE outbox SET status = ?, attempts = attempts + 1, last_error = ? WHERE id = ?",
+		OutboxFailed, reason, id)
+	return err
+}
+
+// FailPending gives up on all pending messages of the user on a channel.
+func (s *Store) FailPending(userID int64, channel, reason string) error {
+	_, err := s.DB.Exec("UPDATE outbox SET status = ?, last_error = ? WHERE user_id = ? AND channel = ? AND status = ?",
+		OutboxFailed, reason, userID, channel, OutboxPending)
+	return err
+}
+
+// PurgeOutbox deletes delivered and failed messages created before the
+// given time and returns how many were removed.
+func (s *Store) PurgeOutbox(before time.Time) (int64, error) {
+	res, err := s.DB.Exec("DELETE FROM outbox WHERE status != ? AND created_at < ?", OutboxPending, before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// OutboxCount is the number of messages on a channel in a status.
+type OutboxCount struct {
+	Channel string `db:"channel"`
+	Status  string `db:"status"`
+	N       int    `db:"n"`
+}
+
+// OutboxStats counts messages created since the given time by channel and
+// status.
+func (s *Store) OutboxStats(since time.Time) ([]OutboxCount, error) {
+	var out []OutboxCount
+	err := s.DB.Select(&out, `SELECT channel, status, COUNT(1) AS n FROM outbox WHERE created_at >= ?
+		GROUP BY channel, status ORDER BY channel, status`, since.Unix())
+	return out, err
+}
+
+// FailedOutbox returns the latest failed messages, newest first.
+func (s *Store) FailedOutbox(limit int) ([]OutboxMessage, error) {
+	var out []OutboxMessage
+	err := s.DB.Select(&out, "SELECT "+outboxColumns+" FROM outbox WHERE status = ? ORDER BY id DESC LIMIT ?", OutboxFailed, limit)
+	return out, err
+}
+
+// OutboxDepth describes the pending messages at a time: how many there are,
+// how many are due and since when the oldest due one has been waiting.
+type OutboxDepth struct {
+	Pending   int    `db:"pending"`
+	Due       int    `db:"due"`
+	OldestDue *int64 `db:"oldest_due"`
+}
+
+// OutboxQueue returns the depth of the queue at now.
+func (s *Store) OutboxQueue(now time.Time) (OutboxDepth, error) {
+	var d OutboxDepth
+	err := s.DB.Get(&d, `SELECT COUNT(1) AS pending, COUNT(CASE WHEN next_at <= ? THEN 1 END) AS due,
+			MIN(CASE WHEN next_at <= ? THEN next_at END) AS oldest_due
+		FROM outbox WHERE status = ?`, now.Unix(), now.Unix(), OutboxPending)
+	return d, err
+}
+
+// OutboxDelay is the time from queueing to delivery of the messages of one
+// priority, in seconds.
+type OutboxDelay struct {
+	Priority int     `db:"priority"`
+	N        int     `db:"n"`
+	Avg      float64 `db:"avg"`
+	Max      int64   `db:"max"`
+}
+
+// OutboxDelays summarizes the delay of messages delivered since the given
+// time by priority.
+func (s *Store) OutboxDelays(since time.Time) ([]OutboxDelay, error) {
+	var out []OutboxDelay
+	err := s.DB.Select(&out, `SELECT priority, COUNT(1) AS n, AVG(sent_at - created_at) AS avg, MAX(sent_at - created_at) AS max
+		FROM outbox WHERE status = ? AND sent_at >= ? GROUP BY priority ORDER BY priority`, OutboxSent, since.Unix())
+	return out, err
+}
@@ -8,10 +8,15 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	_ "modernc.org/sqlite"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
 )
 
 type Store struct {
 	DB *sqlx.DB
+	// Clock is the time source of the store and, through it, of the
+	// scheduler and the bot; tests replace it with a fake clock.
+	Clock timeutil.Clock
 }
 
 type User struct {
@@ -19,8 +24,17 @@ type User struct {
 	TGID           int64  `db:"tg_id"`
 	TZ             string `db:"tz"`
 	ControlEnabled bool   `db:"control_enabled"`
+	// default reminder lead times in minutes; 0 = no reminder
+	RemindStart int `db:"remind_start"`
+	RemindEnd   int `db:"remind_end"`
+	// what to do with unanswered start prompts (ConfirmAuto/ConfirmManual)
+	// and how many minutes to wait for an answer
+	ConfirmPolicy  string `db:"confirm_policy"`
+	ConfirmTimeout int    `db:"confirm_timeout"`
 }
 
+const userColumns = "id, tg_id, tz, control_enabled, remind_start, remind_end, confirm_policy, confirm_timeout"
+
 type Task struct {
 	ID       int64  `db:"id"`
 	UserID   int64  `db:"user_id"`
@@ -31,16 +45,88 @@ type Task struct {
 	EndM     int    `db:"end_m"`
 	DaysMask int    `db:"days_mask"`
 	Enabled  bool   `db:"enabled"`
+	// ArchivedAt is set for deleted tasks: they are hidden from the list and
+	// the scheduler but keep their task_runs for reports.
+	ArchivedAt *int64 `db:"archived_at"`
+	// reminder lead times in minutes before start/finish; nil = user default
+	RemindStart *int `db:"remind_start"`
+	RemindEnd   *int `db:"remind_end"`
+	// CreatedAt is nil for tasks created before it was tracked and never run.
+	CreatedAt *int64 `db:"created_at"`
+	// OnDate is the local date (YYYY-MM-DD) of a one-off task; nil for tasks
+	// that repeat on DaysMask.
+	OnDate *string `db:"on_date"`
+	// RRule is an RFC 5545 recurrence rule counted from RRuleStart
+	// (YYYY-MM-DD); when set it replaces DaysMask.
+	RRule      *string `db:"rrule"`
+	RRuleStart *string `db:"rrule_start"`
+}
+
+const taskColumns = "id, user_id, title, start_h, start_m, end_h, end_m, days_mask, enabled, archived_at, remind_start, remind_end, created_at, on_date, rrule, rrule_start"
+
+// ReminderLeads returns the task's reminder lead times, falling back to the
+// user's defaults where the task has none.
+func (t Task) ReminderLeads(u User) (start, end int) {
+	start, end = u.RemindStart, u.RemindEnd
+	if t.RemindStart != nil {
+		start = *t.RemindStart
+	}
+	if t.RemindEnd != nil {
+		end = *t.RemindEnd
+	}
+	return start, end
+}
+
+// RunsOn reports whether an occurrence of the task starts on the calendar
+// day of day.
+func (t Task) RunsOn(day time.Time) bool {
+	if t.OnDate != nil {
+		return day.Format(timeutil.DateLayout) == *t.OnDate
+	}
+	if r, start, ok := t.Recurrence(); ok {
+		return r.Occurs(start, day)
+	}
+	return t.DaysMask&timeutil.WeekdayBit(day.Weekday()) != 0
+}
+
+// Recurrence returns the task's recurrence rule and its start date; ok is
+// false for tasks without a (valid) rule.
+func (t Task) Recurrence() (r timeutil.Recurrence, start time.Time, ok bool) {
+	if t.RRule == nil || t.RRuleStart == nil {
+		return r, start, false
+	}
+	r, err := timeutil.ParseRecurrence(*t.RRule)
+	if err != nil {
+		return r, start, false
+	}
+	start, err = time.Parse(timeutil.DateLayout, *t.RRuleStart)
+	return r, start, err == nil
+}
+
+// Overnight reports whether the task finishes on the day after it starts
+// (e.g. 22:00–02:00). Its days_mask refers to the start day.
+func (t Task) Overnight() bool {
+	return t.EndH*60+t.EndM < t.StartH*60+t.StartM
 }
 
 type TaskRun struct {
-	ID      int64  `db:"id"`
-	UserID  int64  `db:"user_id"`
-	TaskID  int64  `db:"task_id"`
-	StartTs int64  `db:"start_ts"`
-	EndTs   *int64 `db:"end_ts"`
+	ID      int64   `db:"id"`
+	UserID  int64   `db:"user_id"`
+	TaskID  *int64  `db:"task_id"` // nil for free-form timer runs
+	Title   *string `db:"title"`   // title of a free-form timer run
+	Source  string  `db:"source"`  // RunSchedule or RunTimer
+	StartTs int64   `db:"start_ts"`
+	EndTs   *int64  `db:"end_ts"`
 }
 
+// Run sources.
+const (
+	RunSchedule = "schedule"
+	RunTimer    = "timer"
+)
+
+const runColumns = "id, user_id, task_id, title, source, start_ts, end_ts"
+
 type StatRow struct {
 	Title   string
 	Seconds int64
@@ -52,24 +138,31 @@ func Open(databaseURL string) (*Store, error) {
 	if err := db.Ping(); err != nil { return nil, err }
 	if _, err := db.Exec("PRAGMA foreign_keys = ON;"); err != nil { return nil, err }
 	if err := runMigrations(db); err != nil { return nil, err }
-	return &Store{DB: db}, nil
+	return &Store{DB: db, Clock: timeutil.RealClock()}, nil
 }
 
 func (s *Store) GetOrCreateUser(tgID int64, defaultTZ string) (User, error) {
 	var u User
-	err := s.DB.Get(&u, "SELECT id, tg_id, tz, control_enabled FROM users WHERE tg_id = ?", tgID)
+	err := s.DB.Get(&u, "SELECT "+userColumns+" FROM users WHERE tg_id = ?", tgID)
 	if err == nil { return u, nil }
 	if !errors.Is(err, sql.ErrNoRows) { return u, err }
 	res, err := s.DB.Exec("INSERT INTO users (tg_id, tz, control_enabled) VALUES (?, ?, 0)", tgID, defaultTZ)
 	if err != nil { return u, err }
 	id, _ := res.LastInsertId()
-	u = User{ID: id, TGID: tgID, TZ: defaultTZ, ControlEnabled: false}
+	u = User{ID: id, TGID: tgID, TZ: defaultTZ, ControlEnabled: false, ConfirmPolicy: ConfirmAuto, ConfirmTimeout: DefaultConfirmTimeout}
 	return u, nil
 }
 
 func (s *Store) GetUserByTGID(tgID int64) (User, error) {
 	var u User
-	err := s.DB.Get(&u, "SELECT id, tg_id, tz, control_enabled FROM users WHERE tg_id = ?", tgID)
+	err := s.DB.Get(&u, "SELECT "+userColumns+" FROM users WHERE tg_id = ?", tgID)
+	return u, err
+}
+
+// GetUserByID returns a user by the internal ID.
+func (s *Store) GetUserByID(userID int64) (User, error) {
+	var u User
+	err := s.DB.Get(&u, "SELECT "+userColumns+" FROM users WHERE id = ?", userID)
 	return u, err
 }
 
@@ -78,6 +171,12 @@ func (s *Store) UpdateUserTZ(userID int64, tz string) error {
 	return err
 }
 
+// UpdateUserReminders sets the default reminder lead times (minutes).
+func (s *Store) UpdateUserReminders(userID int64, start, end int) error {
+	_, err := s.DB.Exec("UPDATE users SET remind_start = ?, remind_end = ? WHERE id = ?", start, end, userID)
+	return err
+}
+
 func (s *Store) SetControl(userID int64, enabled bool) error {
 	val := 0
 	if enabled { val = 1 }
@@ -86,28 +185,137 @@ func (s *Store) SetControl(userID int64, enabled bool) error {
 }
 
 func (s *Store) CreateTask(t Task) (int64, error) {
-	res, err := s.DB.Exec(`INSERT INTO tasks (user_id, title, start_h, start_m, end_h, end_m, days_mask, enabled)
-		VALUES (?, ?, ?, ?, ?, ?, ?, 1)`, t.UserID, t.Title, t.StartH, t.StartM, t.EndH, t.EndM, t.DaysMask)
+	res, err := s.DB.Exec(`INSERT INTO tasks (user_id, title, start_h, start_m, end_h, end_m, days_mask, enabled, remind_start, remind_end, created_at, on_date, rrule, rrule_start)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?, ?, ?)`, t.UserID, t.Title, t.StartH, t.StartM, t.EndH, t.EndM, t.DaysMask, t.RemindStart, t.RemindEnd, s.Clock.Now().Unix(), t.OnDate, t.RRule, t.RRuleStart)
 	if err != nil { return 0, err }
 	return res.LastInsertId()
 }
 
 func (s *Store) ListTasks(userID int64) ([]Task, error) {
 	var tasks []Task
-	err := s.DB.Select(&tasks, `SELECT id, user_id, title, start_h, start_m, end_h, end_m, days_mask, enabled
-		FROM tasks WHERE user_id = ? ORDER BY start_h, start_m`, userID)
+	err := s.DB.Select(&tasks, `SELECT `+taskColumns+`
+		FROM tasks WHERE user_id = ? AND archived_at IS NULL ORDER BY start_h, start_m`, userID)
 	return tasks, err
 }
 
 func (s *Store) GetTask(userID, taskID int64) (Task, error) {
 	var t Task
-	err := s.DB.Get(&t, `SELECT id, user_id, title, start_h, start_m, end_h, end_m, days_mask, enabled
+	err := s.DB.Get(&t, `SELECT `+taskColumns+`
 		FROM tasks WHERE id = ? AND user_id = ?`, taskID, userID)
 	return t, err
 }
 
-func (s *Store) DeleteTask(userID, taskID int64) error {
-	_, err := s.DB.Exec("DELETE FROM tasks WHERE id = ? AND user_id = ?", taskID, userID)
+// UpdateTask saves the title, times, days (or date, or rule) and reminders
+// of an existing task.
+func (s *Store) UpdateTask(t Task) error {
+	_, err := s.DB.Exec(`UPDATE tasks SET title = ?, start_h = ?, start_m = ?, end_h = ?, end_m = ?, days_mask = ?,
+		remind_start = ?, remind_end = ?, on_date = ?, rrule = ?, rrule_start = ?
+		WHERE id = ? AND user_id = ?`, t.Title, t.StartH, t.StartM, t.EndH, t.EndM, t.DaysMask,
+		t.RemindStart, t.RemindEnd, t.OnDate, t.RRule, t.RRuleStart, t.ID, t.UserID)
+	return err
+}
+
+// AllTasks returns every task of the user, archived ones included.
+func (s *Store) AllTasks(userID int64) ([]Task, error) {
+	var tasks []Task
+	err := s.DB.Select(&tasks, `SELECT `+taskColumns+` FROM tasks WHERE user_id = ? ORDER BY start_h, start_m`, userID)
+	return tasks, err
+}
+
+// ListArchivedTasks returns the user's deleted (archived) tasks, newest first.
+func (s *Store) ListArchivedTasks(userID int64) ([]Task, error) {
+	var tasks []Task
+	err := s.DB.Select(&tasks, `SELECT `+taskColumns+`
+		FROM tasks WHERE user_id = ? AND archived_at IS NOT NULL ORDER BY archived_at DESC`, userID)
+	return tasks, err
+}
+
+func (s *Store) CountArchivedTasks(userID int64) (int, error) {
+	var n int
+	err := s.DB.Get(&n, "SELECT COUNT(1) FROM tasks WHERE user_id = ? AND archived_at IS NOT NULL", userID)
+	return n, err
+}
+
+// ArchiveTask soft-deletes a task and closes its open run, if any.
+func (s *Store) ArchiveTask(userID, taskID int64, at time.Time) error {
+	return s.archiveTask(userID, taskID, at, at)
+}
+
+// archiveTask archives the task at at and closes its open runs at runEnd,
+// or at at for runs that started later.
+func (s *Store) archiveTask(userID, taskID int64, at, runEnd time.Time) error {
+	tx, err := s.DB.Beginx()
+	if err != nil { return err }
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE tasks SET archived_at = ? WHERE id = ? AND user_id = ? AND archived_at IS NULL", at.Unix(), taskID, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE task_runs SET end_ts = CASE WHEN start_ts <= ? THEN ? ELSE ? END WHERE user_id = ? AND task_id = ? AND end_ts IS NULL",
+		runEnd.Unix(), runEnd.Unix(), at.Unix(), userID, taskID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ArchivePastTasks archives the user's one-off tasks dated before now (in
+// the user's time zone) and tasks whose recurrence rule has ended; overnight
+// ones are kept until the day after their last date is over. Runs left open
+// are closed at the planned end of the last occurrence.
+func (s *Store) ArchivePastTasks(userID int64, now time.Time) (int64, error) {
+	var tasks []Task
+	if err := s.DB.Select(&tasks, `SELECT `+taskColumns+` FROM tasks
+		WHERE user_id = ? AND archived_at IS NULL AND (on_date IS NOT NULL OR rrule IS NOT NULL)`, userID); err != nil {
+		return 0, err
+	}
+	today := now.Format(timeutil.DateLayout)
+	var n int64
+	for _, t := range tasks {
+		last, ok := t.lastDay()
+		if !ok || last.Format(timeutil.DateLayout) >= today { continue }
+		end := timeutil.At(last.Year(), last.Month(), last.Day(), t.EndH, t.EndM, now.Location())
+		if err := s.archiveTask(userID, t.ID, now, end); err != nil { return n, err }
+		n++
+	}
+	return n, nil
+}
+
+// lastDay is the last local date the task occupies (the day after its last
+// date for overnight tasks); ok is false for tasks that never end.
+func (t Task) lastDay() (time.Time, bool) {
+	var last time.Time
+	if t.OnDate != nil {
+		d, err := time.Parse(timeutil.DateLayout, *t.OnDate)
+		if err != nil { return last, false }
+		last = d
+	} else {
+		// the recurrence rule has run out (UNTIL or COUNT)
+		r, start, ok := t.Recurrence()
+		if !ok { return last, false }
+		l, finite := r.Last(start)
+		if !finite { return last, false }
+		last = l
+	}
+	if t.Overnight() { last = last.AddDate(0, 0, 1) }
+	return last, true
+}
+
+// ErrTaskOver is returned when restoring a task whose last date has passed;
+// it would be archived again right away.
+var ErrTaskOver = errors.New("task is over")
+
+// RestoreTask brings an archived task back unless it is already over by now
+// (in the user's time zone).
+func (s *Store) RestoreTask(userID, taskID int64, now time.Time) error {
+	var t Task
+	if err := s.DB.Get(&t, "SELECT "+taskColumns+" FROM tasks WHERE id = ? AND user_id = ? AND archived_at IS NOT NULL", taskID, userID); err != nil { return err }
+	if last, ok := t.lastDay(); ok && last.Format(timeutil.DateLayout) < now.Format(timeutil.DateLayout) { return ErrTaskOver }
+	_, err := s.DB.Exec("UPDATE tasks SET archived_at = NULL WHERE id = ? AND user_id = ? AND archived_at IS NOT NULL", taskID, userID)
+	return err
+}
+
+// PurgeTask permanently deletes an archived task together with its history.
+func (s *Store) PurgeTask(userID, taskID int64) error {
+	_, err := s.DB.Exec("DELETE FROM tasks WHERE id = ? AND user_id = ? AND archived_at IS NOT NULL", taskID, userID)
 	return err
 }
 
@@ -122,27 +330,84 @@ func (s *Store) ToggleTask(userID, taskID int64) (bool, error) {
 }
 
 // --- Time tracking ---
+
+// StartRun opens a scheduled run of the task unless one is open already. It
+// returns ErrRunOpen while a manual timer runs, so that the two never
+// overlap or close each other's runs.
 func (s *Store) StartRun(userID, taskID int64, start time.Time) error {
-	var openCount int
-	if err := s.DB.Get(&openCount, "SELECT COUNT(1) FROM task_runs WHERE user_id=? AND task_id=? AND end_ts IS NULL", userID, taskID); err == nil && openCount > 0 {
-		return nil
+	var open []TaskRun
+	if err := s.DB.Select(&open, "SELECT "+runColumns+" FROM task_runs WHERE user_id = ? AND end_ts IS NULL", userID); err != nil {
+		return err
 	}
-	_, err := s.DB.Exec("INSERT INTO task_runs (user_id, task_id, start_ts) VALUES (?, ?, ?)", userID, taskID, start.Unix())
+	for _, r := range open {
+		if r.Source == RunTimer { return ErrRunOpen }
+		if r.TaskID != nil && *r.TaskID == taskID { return nil }
+	}
+	_, err := s.DB.Exec("INSERT INTO task_runs (user_id, task_id, source, start_ts) VALUES (?, ?, ?, ?)", userID, taskID, RunSchedule, start.Unix())
 	return err
 }
 
+// EndRun closes the task's open scheduled run; timer runs are left alone.
 func (s *Store) EndRun(userID, taskID int64, end time.Time) error {
 	_, err := s.DB.Exec(`UPDATE task_runs
-		SET end_ts = ?
+		SET end_ts = MAX(?, start_ts)
 		WHERE id = (
 			SELECT id FROM task_runs
-			WHERE user_id = ? AND task_id = ? AND end_ts IS NULL
+			WHERE user_id = ? AND task_id = ? AND source = ? AND end_ts IS NULL
 			ORDER BY start_ts DESC
 			LIMIT 1
-		)`, end.Unix(), userID, taskID)
+		)`, end.Unix(), userID, taskID, RunSchedule)
 	return err
 }
 
+// OpenRuns returns the user's task runs that have no end yet.
+func (s *Store) OpenRuns(userID int64) ([]TaskRun, error) {
+	var runs []TaskRun
+	err := s.DB.Select(&runs, `SELECT `+runColumns+` FROM task_runs
+		WHERE user_id = ? AND end_ts IS NULL ORDER BY start_ts`, userID)
+	return runs, err
+}
+
+// ListRuns returns the user's runs that overlap [fromUTC, toUTC), open runs
+// included.
+func (s *Store) ListRuns(userID int64, fromUTC, toUTC time.Time) ([]TaskRun, error) {
+	var runs []TaskRun
+	err := s.DB.Select(&runs, `SELECT `+runColumns+` FROM task_runs
+		WHERE user_id = ? AND start_ts < ? AND (end_ts IS NULL OR end_ts > ?) ORDER BY start_ts`,
+		userID, toUTC.Unix(), fromUTC.Unix())
+	return runs, err
+}
+
+// FirstRuns returns the start (unix) of the earliest run of each of the
+// user's tasks.
+func (s *Store) FirstRuns(userID int64) (map[int64]int64, error) {
+	var rows []struct {
+		TaskID  int64 `db:"task_id"`
+		StartTs int64 `db:"start_ts"`
+	}
+	if err := s.DB.Select(&rows, `SELECT task_id, MIN(start_ts) AS start_ts FROM task_runs
+		WHERE user_id = ? AND task_id IS NOT NULL GROUP BY task_id`, userID); err != nil {
+		return nil, err
+	}
+	out := make(map[int64]int64, len(rows))
+	for _, r := range rows {
+		out[r.TaskID] = r.StartTs
+	}
+	return out, nil
+}
+
+// CloseRun ends an open run; closed is false if it was already closed.
+func (s *Store) CloseRun(runID int64, end time.Time) (closed bool, err error) {
+	res, err := s.DB.Exec("UPDATE task_runs SET end_ts = ? WHERE id = ? AND end_ts IS NULL", end.Unix(), runID)
+	if err != nil { return false, err }
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetStats sums tracked time per task title (free-form timer runs count
+// under their own title). Every run is clipped to
+// [fromUTC, toUTC), so a run crossing midnight (an overnight task) is split
+// between the days it spans.
 func (s *Store) GetStats(userID int64, fromUTC, toUTC time.Time) ([]StatRow, error) {
 	type row struct {
 		Title string `db:"title"`
@@ -151,16 +416,16 @@ func (s *Store) GetStats(userID int64, fromUTC, toUTC time.Time) ([]StatRow, err
 	}
 	var rows []row
 	err := s.DB.Select(&rows, `
-		SELECT t.title as title, r.start_ts, r.end_ts
+		SELECT COALESCE(t.title, r.title, '') as title, r.start_ts, r.end_ts
 		FROM task_runs r
-		JOIN tasks t ON t.id = r.task_id
+		LEFT JOIN tasks t ON t.id = r.task_id
 		WHERE r.user_id = ?
 		  AND r.start_ts < ?
 		  AND (r.end_ts IS NULL OR r.end_ts > ?)
 	`, userID, toUTC.Unix(), fromUTC.Unix())
 	if err != nil { return nil, err }
 
-	now := time.Now().UTC().Unix()
+	now := s.Clock.Now().Unix()
 	acc := map[string]int64{}
 	from := fromUTC.Unix()
 	to := toUTC.Unix()
@@ -185,12 +450,19 @@ func (s *Store) GetStats(userID int64, fromUTC, toUTC time.Time) ([]StatRow, err
 
 func (s *Store) GetTasksForUser(userID int64) ([]Task, error) {
     var tasks []Task
-    err := s.DB.Select(&tasks, `SELECT id, user_id, title, start_h, start_m, end_h, end_m, days_mask, enabled FROM tasks WHERE user_id = ? AND enabled = 1 ORDER BY start_h, start_m`, userID)
+    err := s.DB.Select(&tasks, `SELECT `+taskColumns+` FROM tasks WHERE user_id = ? AND enabled = 1 AND archived_at IS NULL ORDER BY start_h, start_m`, userID)
     return tasks, err
 }
 
+// ListUsers returns all users.
+func (s *Store) ListUsers() ([]User, error) {
+    var users []User
+    err := s.DB.Select(&users, `SELECT `+userColumns+` FROM users ORDER BY id`)
+    return users, err
+}
+
 func (s *Store) UsersWithControlEnabled() ([]User, error) {
     var users []User
-    err := s.DB.Select(&users, `SELECT id, tg_id, tz, control_enabled FROM users WHERE control_enabled = 1`)
+    err := s.DB.Select(&users, `SELECT `+userColumns+` FROM users WHERE control_enabled = 1`)
     return users, err
 }
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRunOpen is returned when a timer is started while another run of the
+// user is still open.
+var ErrRunOpen = errors.New("another run is open")
+
+// ActiveRun is an open run together with its display title.
+type ActiveRun struct {
+	TaskRun
+	DisplayTitle string `db:"display_title"`
+}
+
+// StartTimer opens a manual run for a task (taskID != nil) or a free-form
+// activity with the given title. It refuses to overlap any open run.
+func (s *Store) StartTimer(userID int64, taskID *int64, title string, start time.Time) (int64, error) {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var open int
+	if err := tx.Get(&open, "SELECT COUNT(1) FROM task_runs WHERE user_id = ? AND end_ts IS NULL", userID); err != nil {
+		return 0, err
+	}
+	if open > 0 {
+		return 0, ErrRunOpen
+	}
+	var freeTitle *string
+	if taskID == nil {
+		freeTitle = &title
+	}
+	res, err := tx.Exec("INSERT INTO task_runs (user_id, task_id, title, source, start_ts) VALUES (?, ?, ?, ?, ?)",
+		userID, taskID, freeTitle, RunTimer, start.Unix())
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// ActiveRuns returns the user's open runs with titles, oldest first.
+func (s *Store) ActiveRuns(userID int64) ([]ActiveRun, error) {
+	var runs []ActiveRun
+	err := s.DB.Select(&runs, `SELECT r.id, r.user_id, r.task_id, r.title, r.source, r.start_ts, r.end_ts,
+			COALESCE(t.title, r.title, '') AS display_title
+		FROM task_runs r LEFT JOIN tasks t ON t.id = r.task_id
+		WHERE r.user_id = ? AND r.end_ts IS NULL ORDER BY r.start_ts`, userID)
+	return runs, err
+}
+
+// OpenTimerRuns returns open manual runs of all users.
+func (s *Store) OpenTimerRuns() ([]ActiveRun, error) {
+	var runs []ActiveRun
+	err := s.DB.Select(&runs, `SELECT r.id, r.user_id, r.task_id, r.title, r.source, r.start_ts, r.end_ts,
+			COALESCE(t.title, r.title, '') AS display_title
+		FROM task_runs r LEFT JOIN tasks t ON t.id = r.task_id
+		WHERE r.source = ? AND r.end_ts IS NULL`, RunTimer)
+	return runs, err
+}
@@ -0,0 +1,11 @@
+package timeutil
+
+import "github.com/jonboulle/clockwork"
+
+// Clock tells the current time. It is the clock gocron takes, so one fake
+// clock (clockwork.NewFakeClock) can drive the store, the scheduler and its
+// jobs through a schedule deterministically.
+type Clock = clockwork.Clock
+
+// RealClock is the wall clock.
+func RealClock() Clock { return clockwork.NewRealClock() }
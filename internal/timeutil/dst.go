@@ -0,0 +1,95 @@
+package timeutil
+
+import "time"
+
+// Daylight saving time
+//
+// A wall clock time can be missing (the gap when clocks jump forward, e.g.
+// 03:00–04:00 in Europe/Kyiv on the last Sunday of March) or happen twice
+// (the overlap when they fall back). time.Date picks either side in both
+// cases, so local times are built with At, which follows RFC 5545:
+//
+//   - a time in a gap is read with the UTC offset from before the gap, i.e.
+//     it moves forward by the length of the gap (03:30 becomes 04:30);
+//   - a repeated time is the first of the two (the one before the clocks
+//     fall back).
+//
+// Days are always calendar days: a local day is [midnight, next midnight),
+// which lasts 23 or 25 hours on transition days.
+
+// At returns the wall clock time h:m on the given date in loc. Out of range
+// values are normalised as by time.Date.
+func At(year int, month time.Month, day, h, m int, loc *time.Location) time.Time {
+	wall := time.Date(year, month, day, h, m, 0, 0, time.UTC)
+	before, after := offsetsAround(wall, loc)
+	var out time.Time
+	for _, off := range []int{before, after} {
+		t := wall.Add(-time.Duration(off) * time.Second).In(loc)
+		if _, o := t.Zone(); o == off && (out.IsZero() || t.Before(out)) {
+			out = t
+		}
+	}
+	if out.IsZero() {
+		// in the gap
+		out = wall.Add(-time.Duration(before) * time.Second).In(loc)
+	}
+	return out
+}
+
+// offsetsAround returns the UTC offsets of loc a day before and after the
+// wall clock time wall (given as a UTC time).
+func offsetsAround(wall time.Time, loc *time.Location) (before, after int) {
+	_, before = wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, after = wall.Add(24 * time.Hour).In(loc).Zone()
+	return before, after
+}
+
+// InGap reports whether h:m does not exist on the given date in loc.
+func InGap(year int, month time.Month, day, h, m int, loc *time.Location) bool {
+	t := At(year, month, day, h, m, loc)
+	return t.Hour() != h || t.Minute() != m
+}
+
+// Repeated reports whether h:m happens twice on the given date in loc.
+func Repeated(year int, month time.Month, day, h, m int, loc *time.Location) bool {
+	wall := time.Date(year, month, day, h, m, 0, 0, time.UTC)
+	before, after := offsetsAround(wall, loc)
+	if before == after {
+		return false
+	}
+	for _, off := range []int{before, after} {
+		t := wall.Add(-time.Duration(off) * time.Second).In(loc)
+		if _, o := t.Zone(); o != off {
+			return false
+		}
+	}
+	return true
+}
+
+// AddDays returns midnight of the calendar day n days after t's day.
+func AddDays(t time.Time, n int) time.Time {
+	return At(t.Year(), t.Month(), t.Day()+n, 0, 0, t.Location())
+}
+
+// SinceMidnight is the wall clock reading of t as a duration, 0 to 24h
+// whatever the length of the day.
+func SinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+}
+
+// NextGap returns the first day from from on (within maxGapScan days) on
+// which h:m falls into a daylight saving gap in from's location.
+func NextGap(from time.Time, h, m int) (time.Time, bool) {
+	day := StartOfDay(from)
+	for i := 0; i < maxGapScan; i++ {
+		d := AddDays(day, i)
+		if InGap(d.Year(), d.Month(), d.Day(), h, m, d.Location()) {
+			return d, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// maxGapScan is how far ahead NextGap looks, a year and a bit.
+const maxGapScan = 400
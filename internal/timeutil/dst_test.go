@@ -0,0 +1,88 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("no time zone data for %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestAtGap(t *testing.T) {
+	kyiv := mustLoad(t, "Europe/Kyiv")
+	if !InGap(2026, time.March, 29, 3, 30, kyiv) {
+		t.Fatal("03:30 on 2026-03-29 should be in the Kyiv gap")
+	}
+	got := At(2026, time.March, 29, 3, 30, kyiv)
+	if h, m := got.Hour(), got.Minute(); h != 4 || m != 30 {
+		t.Errorf("At in the gap = %s, want 04:30", got)
+	}
+	if name, _ := got.Zone(); name != "EEST" {
+		t.Errorf("At in the gap zone = %s, want EEST", name)
+	}
+	if want := time.Date(2026, time.March, 29, 1, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("At in the gap = %s, want %s", got.UTC(), want)
+	}
+	if InGap(2026, time.March, 29, 2, 30, kyiv) || InGap(2026, time.March, 28, 3, 30, kyiv) {
+		t.Error("times outside the gap reported as in it")
+	}
+}
+
+func TestAtRepeated(t *testing.T) {
+	kyiv := mustLoad(t, "Europe/Kyiv")
+	if !Repeated(2026, time.October, 25, 3, 30, kyiv) {
+		t.Fatal("03:30 on 2026-10-25 should happen twice in Kyiv")
+	}
+	if Repeated(2026, time.October, 25, 5, 0, kyiv) || Repeated(2026, time.October, 24, 3, 30, kyiv) {
+		t.Error("times outside the overlap reported as repeated")
+	}
+	got := At(2026, time.October, 25, 3, 30, kyiv)
+	if want := time.Date(2026, time.October, 25, 0, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("At repeated = %s, want the first instance %s", got.UTC(), want)
+	}
+	if InGap(2026, time.October, 25, 3, 30, kyiv) {
+		t.Error("repeated time reported as in the gap")
+	}
+}
+
+func TestPeriodRangeDayLength(t *testing.T) {
+	kyiv := mustLoad(t, "Europe/Kyiv")
+	tests := []struct {
+		day  time.Time
+		want time.Duration
+	}{
+		{time.Date(2026, time.March, 29, 12, 0, 0, 0, kyiv), 23 * time.Hour},
+		{time.Date(2026, time.October, 25, 12, 0, 0, 0, kyiv), 25 * time.Hour},
+		{time.Date(2026, time.October, 26, 12, 0, 0, 0, kyiv), 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		r := PeriodRange(PeriodDay, tt.day)
+		if got := r.To.Sub(r.From); got != tt.want {
+			t.Errorf("PeriodRange(day, %s) lasts %s, want %s", tt.day.Format(DateLayout), got, tt.want)
+		}
+		if r.From.Hour() != 0 || r.To.Hour() != 0 {
+			t.Errorf("PeriodRange(day, %s) = [%s, %s), want midnight to midnight", tt.day.Format(DateLayout), r.From, r.To)
+		}
+	}
+}
+
+func TestStartOfDayMidnightGap(t *testing.T) {
+	santiago := mustLoad(t, "America/Santiago")
+	// Chile springs forward at midnight, so 2026-09-06 starts at 01:00.
+	got := StartOfDay(time.Date(2026, time.September, 6, 12, 0, 0, 0, santiago))
+	if got.Day() != 6 || got.Hour() != 1 || got.Minute() != 0 {
+		t.Errorf("StartOfDay = %s, want 2026-09-06 01:00", got)
+	}
+	if prev := got.Add(-time.Nanosecond); prev.Day() != 5 {
+		t.Errorf("instant before StartOfDay is %s, want the previous day", prev)
+	}
+	if day := AddDays(got, -1); day.Day() != 5 || day.Hour() != 0 {
+		t.Errorf("AddDays(-1) = %s, want 2026-09-05 00:00", day)
+	}
+}
@@ -0,0 +1,157 @@
+package timeutil
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Report period kinds.
+const (
+	PeriodDay    = "day"
+	PeriodWeek   = "week"
+	PeriodMonth  = "month"
+	PeriodAll    = "all"
+	PeriodCustom = "custom"
+)
+
+// DateLayout is the ISO date format used in commands and callback data.
+const DateLayout = "2006-01-02"
+
+// Range is a local-time interval [From, To) of a given kind.
+type Range struct {
+	Kind string
+	From time.Time
+	To   time.Time
+}
+
+// StartOfDay returns local midnight of t's calendar day (its first instant
+// if midnight falls into a daylight saving gap).
+func StartOfDay(t time.Time) time.Time {
+	return At(t.Year(), t.Month(), t.Day(), 0, 0, t.Location())
+}
+
+// PeriodRange returns the day, week (Mon–Sun) or month containing anchor.
+// Any other kind yields everything from the Unix epoch up to the next day.
+func PeriodRange(kind string, anchor time.Time) Range {
+	day := StartOfDay(anchor)
+	switch kind {
+	case PeriodDay:
+		return Range{Kind: kind, From: day, To: AddDays(day, 1)}
+	case PeriodWeek:
+		weekday := int(day.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		monday := AddDays(day, -(weekday - 1))
+		return Range{Kind: kind, From: monday, To: AddDays(monday, 7)}
+	case PeriodMonth:
+		first := At(day.Year(), day.Month(), 1, 0, 0, day.Location())
+		return Range{Kind: kind, From: first, To: At(day.Year(), day.Month()+1, 1, 0, 0, day.Location())}
+	}
+	return Range{Kind: PeriodAll, From: time.Unix(0, 0).In(anchor.Location()), To: AddDays(day, 1)}
+}
+
+// Days is the number of calendar days in the range.
+func (r Range) Days() int {
+	n := 0
+	for d := r.From; d.Before(r.To); d = AddDays(d, 1) {
+		n++
+	}
+	return n
+}
+
+// Shift moves the range by n periods (n < 0 goes back). Custom ranges move by
+// their own length in days.
+func (r Range) Shift(n int) Range {
+	switch r.Kind {
+	case PeriodDay:
+		return Range{Kind: r.Kind, From: AddDays(r.From, n), To: AddDays(r.To, n)}
+	case PeriodWeek:
+		return Range{Kind: r.Kind, From: AddDays(r.From, 7*n), To: AddDays(r.To, 7*n)}
+	case PeriodMonth:
+		loc := r.From.Location()
+		return Range{Kind: r.Kind, From: At(r.From.Year(), r.From.Month()+time.Month(n), 1, 0, 0, loc), To: At(r.From.Year(), r.From.Month()+time.Month(n+1), 1, 0, 0, loc)}
+	case PeriodCustom:
+		d := r.Days() * n
+		return Range{Kind: r.Kind, From: AddDays(r.From, d), To: AddDays(r.To, d)}
+	}
+	return r
+}
+
+// Label is a human-readable (Russian) description of the range.
+func (r Range) Label() string {
+	last := r.To.AddDate(0, 0, -1)
+	switch r.Kind {
+	case PeriodDay:
+		return r.From.Format("02.01.2006")
+	case PeriodWeek:
+		return fmt.Sprintf("неделю %s–%s", r.From.Format("02.01"), last.Format("02.01.2006"))
+	case PeriodMonth:
+		return fmt.Sprintf("%s %d", monthNames[r.From.Month()-1], r.From.Year())
+	case PeriodAll:
+		return "всё время"
+	}
+	if r.Days() == 1 {
+		return r.From.Format("02.01.2006")
+	}
+	return fmt.Sprintf("%s–%s", r.From.Format("02.01.2006"), last.Format("02.01.2006"))
+}
+
+var monthNames = []string{"январь", "февраль", "март", "апрель", "май", "июнь", "июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь"}
+
+// parseDate accepts 2026-09-01 and 01.09.2026 in loc.
+func parseDate(s string, loc *time.Location) (time.Time, bool) {
+	for _, layout := range []string{DateLayout, "02.01.2006", "2.1.2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return At(t.Year(), t.Month(), t.Day(), 0, 0, loc), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseRange understands report periods typed by the user, relative to now
+// (which carries the user's location):
+//
+//	сегодня, вчера, неделя, прошлая неделя, месяц, прошлый месяц, всё время,
+//	2026-09-01, 2026-09-01..2026-09-30, 01.09.2026-30.09.2026
+func ParseRange(text string, now time.Time) (Range, bool) {
+	s := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	s = strings.ReplaceAll(s, "ё", "е")
+	switch s {
+	case "сегодня", "день", "today":
+		return PeriodRange(PeriodDay, now), true
+	case "вчера", "yesterday":
+		return PeriodRange(PeriodDay, AddDays(now, -1)), true
+	case "неделя", "эта неделя", "week":
+		return PeriodRange(PeriodWeek, now), true
+	case "прошлая неделя", "last week":
+		return PeriodRange(PeriodWeek, AddDays(now, -7)), true
+	case "месяц", "этот месяц", "month":
+		return PeriodRange(PeriodMonth, now), true
+	case "прошлый месяц", "last month":
+		return PeriodRange(PeriodMonth, now).Shift(-1), true
+	case "все время", "все", "all":
+		return PeriodRange(PeriodAll, now), true
+	}
+	loc := now.Location()
+	for _, sep := range []string{"..", " - ", "—", "–", "-", " "} {
+		a, b, ok := strings.Cut(s, sep)
+		if !ok {
+			continue
+		}
+		from, ok1 := parseDate(strings.TrimSpace(a), loc)
+		to, ok2 := parseDate(strings.TrimSpace(b), loc)
+		if !ok1 || !ok2 {
+			continue
+		}
+		if to.Before(from) {
+			from, to = to, from
+		}
+		return Range{Kind: PeriodCustom, From: from, To: AddDays(to, 1)}, true
+	}
+	if d, ok := parseDate(s, loc); ok {
+		return Range{Kind: PeriodDay, From: d, To: AddDays(d, 1)}, true
+	}
+	return Range{}, false
+}
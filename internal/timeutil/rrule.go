@@ -0,0 +1,503 @@
+package timeutil
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recurrence frequencies.
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxCount caps COUNT so that counting occurrences stays cheap.
+const maxCount = 1000
+
+// WeekdayNum is a BYDAY entry: every Day of the period when N is 0, the
+// N-th one when N > 0, the N-th from the end when N < 0 ("1MO", "-1FR").
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// Recurrence is an RFC 5545 recurrence rule (RRULE) over whole days. Only
+// the parts that make sense for a daily schedule are supported: FREQ,
+// INTERVAL, BYDAY, BYMONTHDAY, BYMONTH, UNTIL and COUNT. The rule is
+// anchored at a start date that counts as the first period (and, with
+// COUNT, the first candidate occurrence).
+type Recurrence struct {
+	Freq       string
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	Until      time.Time // last allowed date; zero for none
+	Count      int       // number of occurrences; 0 for no limit
+}
+
+var dayCode = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+var dayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// ParseRecurrence reads an RRULE value such as
+// "FREQ=MONTHLY;BYDAY=1MO;COUNT=6" (an "RRULE:" prefix is allowed).
+func ParseRecurrence(s string) (Recurrence, error) {
+	r := Recurrence{Interval: 1}
+	s = strings.TrimPrefix(strings.TrimSpace(strings.ToUpper(s)), "RRULE:")
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(part, "=")
+		var err error
+		switch k {
+		case "FREQ":
+			switch v {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				r.Freq = v
+			default:
+				return r, fmt.Errorf("unsupported FREQ %q", v)
+			}
+		case "INTERVAL":
+			if r.Interval, err = strconv.Atoi(v); err != nil || r.Interval < 1 {
+				return r, fmt.Errorf("bad INTERVAL %q", v)
+			}
+		case "COUNT":
+			if r.Count, err = strconv.Atoi(v); err != nil || r.Count < 1 || r.Count > maxCount {
+				return r, fmt.Errorf("bad COUNT %q", v)
+			}
+		case "UNTIL":
+			// a date, or a date-time of which only the date is kept
+			if len(v) < 8 {
+				return r, fmt.Errorf("bad UNTIL %q", v)
+			}
+			if r.Until, err = time.Parse("20060102", v[:8]); err != nil {
+				return r, fmt.Errorf("bad UNTIL %q", v)
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				wn, ok := parseWeekdayNum(d)
+				if !ok {
+					return r, fmt.Errorf("bad BYDAY %q", d)
+				}
+				r.ByDay = append(r.ByDay, wn)
+			}
+		case "BYMONTHDAY":
+			if r.ByMonthDay, err = parseInts(v, -31, 31); err != nil {
+				return r, fmt.Errorf("bad BYMONTHDAY %q", v)
+			}
+		case "BYMONTH":
+			if r.ByMonth, err = parseInts(v, 1, 12); err != nil {
+				return r, fmt.Errorf("bad BYMONTH %q", v)
+			}
+		case "WKST":
+			// weeks always start on Monday here
+		default:
+			return r, fmt.Errorf("unsupported rule part %s", k)
+		}
+	}
+	if r.Freq == "" {
+		return r, errors.New("FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return r, errors.New("COUNT and UNTIL are exclusive")
+	}
+	for _, wn := range r.ByDay {
+		if wn.N != 0 && r.Freq != FreqMonthly && r.Freq != FreqYearly {
+			return r, errors.New("numbered BYDAY needs FREQ=MONTHLY or YEARLY")
+		}
+	}
+	return r, nil
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, bool) {
+	if len(s) < 2 {
+		return WeekdayNum{}, false
+	}
+	day, ok := dayCode[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, false
+	}
+	wn := WeekdayNum{Day: day}
+	if num := strings.TrimPrefix(s[:len(s)-2], "+"); num != "" {
+		n, err := strconv.Atoi(num)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, false
+		}
+		wn.N = n
+	}
+	return wn, true
+}
+
+func parseInts(s string, lo, hi int) ([]int, error) {
+	var out []int
+	for _, p := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n == 0 || n < lo || n > hi {
+			return nil, fmt.Errorf("bad number %q", p)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// String formats the rule as an RRULE value.
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, wn := range r.ByDay {
+			days[i] = dayCodes[wn.Day]
+			if wn.N != 0 {
+				days[i] = strconv.Itoa(wn.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+func joinInts(ns []int) string {
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+// civil is the calendar date of t as a UTC midnight, so that day arithmetic
+// is not disturbed by DST changes.
+func civil(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(a, b time.Time) int { return int(b.Sub(a).Hours() / 24) }
+
+// mondayOf is the Monday of the week of a civil date.
+func mondayOf(d time.Time) time.Time { return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7)) }
+
+func daysIn(d time.Time) int {
+	return time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func (wn WeekdayNum) matches(d time.Time) bool {
+	if d.Weekday() != wn.Day {
+		return false
+	}
+	switch {
+	case wn.N > 0:
+		return (d.Day()-1)/7+1 == wn.N
+	case wn.N < 0:
+		return (daysIn(d)-d.Day())/7+1 == -wn.N
+	}
+	return true
+}
+
+func monthDayMatches(d time.Time, n int) bool {
+	if n < 0 {
+		n = daysIn(d) + n + 1
+	}
+	return d.Day() == n
+}
+
+func (r Recurrence) byDay(d time.Time) bool {
+	for _, wn := range r.ByDay {
+		if wn.matches(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Recurrence) byMonthDay(d time.Time) bool {
+	for _, n := range r.ByMonthDay {
+		if monthDayMatches(d, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether civil date d fits the rule anchored at civil date
+// s, ignoring COUNT.
+func (r Recurrence) matches(s, d time.Time) bool {
+	if d.Before(s) || (!r.Until.IsZero() && d.After(civil(r.Until))) {
+		return false
+	}
+	if len(r.ByMonth) > 0 {
+		in := false
+		for _, m := range r.ByMonth {
+			in = in || int(d.Month()) == m
+		}
+		if !in {
+			return false
+		}
+	}
+	interval := max(r.Interval, 1)
+	// days within the period: explicit BY* lists, or the start's own day
+	days := func(def bool) bool {
+		switch {
+		case len(r.ByDay) > 0 && len(r.ByMonthDay) > 0:
+			return r.byDay(d) && r.byMonthDay(d)
+		case len(r.ByDay) > 0:
+			return r.byDay(d)
+		case len(r.ByMonthDay) > 0:
+			return r.byMonthDay(d)
+		}
+		return def
+	}
+	switch r.Freq {
+	case FreqDaily:
+		return daysBetween(s, d)%interval == 0 && days(true)
+	case FreqWeekly:
+		return (daysBetween(mondayOf(s), mondayOf(d))/7)%interval == 0 && days(d.Weekday() == s.Weekday())
+	case FreqMonthly:
+		months := (d.Year()-s.Year())*12 + int(d.Month()) - int(s.Month())
+		return months%interval == 0 && days(d.Day() == s.Day())
+	case FreqYearly:
+		if (d.Year()-s.Year())%interval != 0 {
+			return false
+		}
+		if len(r.ByMonth) == 0 && d.Month() != s.Month() {
+			return false
+		}
+		return days(d.Day() == s.Day())
+	}
+	return false
+}
+
+// Occurs reports whether the rule anchored at start has an occurrence on
+// the calendar day of day.
+func (r Recurrence) Occurs(start, day time.Time) bool {
+	s, d := civil(start), civil(day)
+	if !r.matches(s, d) {
+		return false
+	}
+	if r.Count == 0 {
+		return true
+	}
+	last := r.countEnd(s)
+	return !last.IsZero() && !d.After(last)
+}
+
+// countEnds remembers the final date of COUNT rules by rule and start, so
+// that callers asking day by day (export, reports, scheduling) do not
+// rescan from the start on every call.
+var countEnds sync.Map
+
+func (r Recurrence) countEnd(s time.Time) time.Time {
+	key := r.String() + "@" + s.Format("20060102")
+	if last, ok := countEnds.Load(key); ok {
+		return last.(time.Time)
+	}
+	last, _ := r.Last(s)
+	countEnds.Store(key, last)
+	return last
+}
+
+// Between lists the occurrence dates in [from, to), as civil dates (UTC
+// midnights).
+func (r Recurrence) Between(start, from, to time.Time) []time.Time {
+	s, f, t := civil(start), civil(from), civil(to)
+	var out []time.Time
+	n := 0
+	c := f
+	if r.Count > 0 || c.Before(s) {
+		c = s
+	}
+	for ; c.Before(t); c = c.AddDate(0, 0, 1) {
+		if !r.matches(s, c) {
+			continue
+		}
+		n++
+		if r.Count > 0 && n > r.Count {
+			break
+		}
+		if !c.Before(f) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// maxScan bounds searches for occurrences of rules that rarely or never
+// match (BYMONTHDAY=31;BYMONTH=2).
+const maxScan = 100 * 366
+
+// Last returns the date of the final occurrence of a rule limited by COUNT
+// or UNTIL; finite is false for endless rules. A limited rule that never
+// matches gives the zero time.
+func (r Recurrence) Last(start time.Time) (last time.Time, finite bool) {
+	s := civil(start)
+	switch {
+	case r.Count > 0:
+		n := 0
+		for c, i := s, 0; i < maxScan; c, i = c.AddDate(0, 0, 1), i+1 {
+			if r.matches(s, c) {
+				last = c
+				if n++; n == r.Count {
+					break
+				}
+			}
+		}
+		return last, true
+	case !r.Until.IsZero():
+		for c := civil(r.Until); !c.Before(s); c = c.AddDate(0, 0, -1) {
+			if r.matches(s, c) {
+				return c, true
+			}
+		}
+		return time.Time{}, true
+	}
+	return time.Time{}, false
+}
+
+// Next returns the first occurrence on or after from.
+func (r Recurrence) Next(start, from time.Time) (time.Time, bool) {
+	s, c := civil(start), civil(from)
+	if c.Before(s) {
+		c = s
+	}
+	for i := 0; i < maxScan; i, c = i+1, c.AddDate(0, 0, 1) {
+		if !r.Until.IsZero() && c.After(civil(r.Until)) {
+			break
+		}
+		if r.matches(s, c) {
+			if r.Count > 0 && !r.Occurs(s, c) {
+				break
+			}
+			return c, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Weekdays is a days mask of the weekdays the rule can fall on.
+func (r Recurrence) Weekdays(start time.Time) int {
+	switch {
+	case len(r.ByDay) > 0:
+		mask := 0
+		for _, wn := range r.ByDay {
+			mask |= WeekdayBit(wn.Day)
+		}
+		return mask
+	case len(r.ByMonthDay) > 0 || r.Freq == FreqDaily:
+		return MaskDaily()
+	}
+	return WeekdayBit(start.Weekday())
+}
+
+var dayShort = []string{"вс", "пн", "вт", "ср", "чт", "пт", "сб"}
+
+// plural picks the Russian form for n: один день, два дня, пять дней.
+func plural(n int, one, few, many string) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return one
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return few
+	}
+	return many
+}
+
+// Describe is the rule in Russian: "каждые 2 недели: пн, ср",
+// "каждый месяц: 1-й пн, 10 раз".
+func (r Recurrence) Describe(start time.Time) string {
+	n := max(r.Interval, 1)
+	var every string
+	switch r.Freq {
+	case FreqDaily:
+		every = "каждый день"
+		if n > 1 {
+			every = fmt.Sprintf("каждые %d %s", n, plural(n, "день", "дня", "дней"))
+		}
+	case FreqWeekly:
+		every = "каждую неделю"
+		if n > 1 {
+			every = fmt.Sprintf("каждые %d %s", n, plural(n, "неделю", "недели", "недель"))
+		}
+	case FreqMonthly:
+		every = "каждый месяц"
+		if n > 1 {
+			every = fmt.Sprintf("каждые %d %s", n, plural(n, "месяц", "месяца", "месяцев"))
+		}
+	case FreqYearly:
+		every = "каждый год"
+		if n > 1 {
+			every = fmt.Sprintf("каждые %d %s", n, plural(n, "год", "года", "лет"))
+		}
+	}
+
+	var what []string
+	for _, wn := range r.ByDay {
+		switch {
+		case wn.N == -1:
+			what = append(what, "последний "+dayShort[wn.Day])
+		case wn.N < 0:
+			what = append(what, fmt.Sprintf("%d-й с конца %s", -wn.N, dayShort[wn.Day]))
+		case wn.N > 0:
+			what = append(what, fmt.Sprintf("%d-й %s", wn.N, dayShort[wn.Day]))
+		default:
+			what = append(what, dayShort[wn.Day])
+		}
+	}
+	for _, d := range r.ByMonthDay {
+		if d == -1 {
+			what = append(what, "последний день")
+		} else if d < 0 {
+			what = append(what, fmt.Sprintf("%d-й день с конца", -d))
+		} else {
+			what = append(what, fmt.Sprintf("%d-го", d))
+		}
+	}
+	for _, m := range r.ByMonth {
+		what = append(what, monthNames[m-1])
+	}
+	if len(what) == 0 {
+		switch r.Freq {
+		case FreqWeekly:
+			what = append(what, dayShort[start.Weekday()])
+		case FreqMonthly:
+			what = append(what, fmt.Sprintf("%d-го", start.Day()))
+		case FreqYearly:
+			what = append(what, start.Format("02.01"))
+		}
+	}
+
+	out := every
+	if len(what) > 0 {
+		out += ": " + strings.Join(what, ", ")
+	}
+	if !r.Until.IsZero() {
+		out += ", до " + r.Until.Format("02.01.2006")
+	}
+	if r.Count > 0 {
+		out += fmt.Sprintf(", %d %s", r.Count, plural(r.Count, "раз", "раза", "раз"))
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package timeutil
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse(DateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestRecurrenceDates(t *testing.T) {
+	tests := []struct {
+		rule, start, from, to string
+		want                  []string
+	}{
+		{"FREQ=DAILY;INTERVAL=3", "2026-10-01", "2026-10-01", "2026-10-11",
+			[]string{"2026-10-01", "2026-10-04", "2026-10-07", "2026-10-10"}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", "2026-10-05", "2026-10-01", "2026-11-01",
+			[]string{"2026-10-05", "2026-10-07", "2026-10-19", "2026-10-21"}},
+		{"FREQ=MONTHLY;BYDAY=1MO", "2026-10-01", "2026-10-01", "2027-01-01",
+			[]string{"2026-10-05", "2026-11-02", "2026-12-07"}},
+		{"FREQ=MONTHLY;BYDAY=-1FR", "2026-10-01", "2026-10-01", "2027-01-01",
+			[]string{"2026-10-30", "2026-11-27", "2026-12-25"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "2026-01-01", "2026-01-01", "2026-05-01",
+			[]string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"}},
+		{"FREQ=WEEKLY;BYDAY=TU,TH;COUNT=3", "2026-10-01", "2026-10-01", "2026-11-01",
+			[]string{"2026-10-01", "2026-10-06", "2026-10-08"}},
+		// COUNT is counted from the start, not from the range
+		{"FREQ=WEEKLY;BYDAY=TU,TH;COUNT=3", "2026-10-01", "2026-10-07", "2026-11-01",
+			[]string{"2026-10-08"}},
+		{"FREQ=DAILY;UNTIL=20261005T235959Z", "2026-10-01", "2026-09-20", "2026-10-20",
+			[]string{"2026-10-01", "2026-10-02", "2026-10-03", "2026-10-04", "2026-10-05"}},
+	}
+	for _, tt := range tests {
+		r, err := ParseRecurrence(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseRecurrence(%q): %v", tt.rule, err)
+		}
+		start, from, to := date(tt.start), date(tt.from), date(tt.to)
+		var got []string
+		for _, d := range r.Between(start, from, to) {
+			got = append(got, d.Format(DateLayout))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s from %s: Between = %v, want %v", tt.rule, tt.start, got, tt.want)
+		}
+		for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+			day := d.Format(DateLayout)
+			if want := slices.Contains(tt.want, day); r.Occurs(start, d) != want {
+				t.Errorf("%s from %s: Occurs(%s) = %v, want %v", tt.rule, tt.start, day, !want, want)
+			}
+		}
+	}
+}
+
+func TestRecurrenceLast(t *testing.T) {
+	tests := []struct {
+		rule, start string
+		want        string
+		finite      bool
+	}{
+		{"FREQ=WEEKLY;BYDAY=TU,TH;COUNT=3", "2026-10-01", "2026-10-08", true},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=2", "2026-01-15", "2026-02-28", true},
+		{"FREQ=DAILY;UNTIL=20261005", "2026-10-01", "2026-10-05", true},
+		{"FREQ=WEEKLY;INTERVAL=2", "2026-10-01", "", false},
+	}
+	for _, tt := range tests {
+		r, err := ParseRecurrence(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseRecurrence(%q): %v", tt.rule, err)
+		}
+		last, finite := r.Last(date(tt.start))
+		got := ""
+		if !last.IsZero() {
+			got = last.Format(DateLayout)
+		}
+		if got != tt.want || finite != tt.finite {
+			t.Errorf("%s from %s: Last = %q, %v, want %q, %v", tt.rule, tt.start, got, finite, tt.want, tt.finite)
+		}
+	}
+}
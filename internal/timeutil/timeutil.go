@@ -43,43 +43,28 @@ func ParseHHMM(s string) (int, int, bool) {
 	return t.Hour(), t.Minute(), true
 }
 
-func NextLocalMidnightPlus(tz string, plusMinutes int) (time.Time, error) {
+func NextLocalMidnightPlus(clock Clock, tz string, plusMinutes int) (time.Time, error) {
 	loc, err := time.LoadLocation(tz)
 	if err != nil { return time.Time{}, err }
-	now := time.Now().In(loc)
-	next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, plusMinutes, 0, 0, loc)
+	now := clock.Now().In(loc)
+	next := At(now.Year(), now.Month(), now.Day()+1, 0, plusMinutes, loc)
 	return next.UTC(), nil
 }
 
-func LocalDateTime(tz string, h, m, dayOffset int) (time.Time, error) {
+func LocalDateTime(clock Clock, tz string, h, m, dayOffset int) (time.Time, error) {
 	loc, err := time.LoadLocation(tz)
 	if err != nil { return time.Time{}, err }
-	now := time.Now().In(loc)
-	dt := time.Date(now.Year(), now.Month(), now.Day()+dayOffset, h, m, 0, 0, loc)
-	return dt, nil
+	return DateTimeOn(clock.Now().In(loc), h, m, dayOffset), nil
 }
 
-// RangeUTC returns [fromUTC, toUTC) for a given period in the user's TZ.
-func RangeUTC(period, tz string) (time.Time, time.Time, error) {
-	loc, err := time.LoadLocation(tz)
-	if err != nil { return time.Time{}, time.Time{}, err }
-	now := time.Now().In(loc)
+// DateTimeOn returns h:m on the calendar day of day shifted by dayOffset days,
+// in day's location; see At for daylight saving transitions.
+func DateTimeOn(day time.Time, h, m, dayOffset int) time.Time {
+	return At(day.Year(), day.Month(), day.Day()+dayOffset, h, m, day.Location())
+}
 
-	var start time.Time
-	switch period {
-	case "day":
-		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
-	case "week":
-		weekday := int(now.Weekday())
-		if weekday == 0 { weekday = 7 }
-		monday := now.AddDate(0,0, -(weekday-1))
-		start = time.Date(monday.Year(), monday.Month(), monday.Day(), 0,0,0,0, loc)
-	case "month":
-		start = time.Date(now.Year(), now.Month(), 1, 0,0,0,0, loc)
-	case "all":
-		return time.Unix(0,0).UTC(), time.Now().UTC(), nil
-	default:
-		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
-	}
-	return start.UTC(), time.Now().UTC(), nil
+// ShiftMask moves every day in mask by days (Mon+1 = Tue, Sun+1 = Mon).
+func ShiftMask(mask, days int) int {
+	days = ((days % 7) + 7) % 7
+	return ((mask << days) | (mask >> (7 - days))) & MaskDaily()
 }
@@ -0,0 +1,730 @@
+// Package when understands times, time ranges, durations and days written
+// in plain Russian or English: "с 9 до половины одиннадцатого",
+// "завтра в 14", "каждый вторник и четверг", "every weekday 9am-5pm",
+// "на 45 минут".
+package when
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+// Clock is a time of day.
+type Clock struct {
+	H, M int
+}
+
+// Add moves the clock by d, wrapping past midnight.
+func (c Clock) Add(d time.Duration) Clock {
+	m := (c.H*60 + c.M + int(d/time.Minute)) % (24 * 60)
+	if m < 0 {
+		m += 24 * 60
+	}
+	return Clock{m / 60, m % 60}
+}
+
+// Result is what Parse found in a phrase. Rest is the phrase with every
+// recognised part removed (typically the task title).
+type Result struct {
+	Start    Clock
+	HasStart bool
+	End      Clock
+	HasEnd   bool
+	Duration time.Duration
+	// DaysMask is the set of weekdays (timeutil bits); 0 if none was named.
+	DaysMask int
+	// DayOffset is 0 for "сегодня", 1 for "завтра", 2 for "послезавтра".
+	DayOffset int
+	HasDay    bool
+	Rest      string
+}
+
+// Until returns the end time, given either explicitly or as a duration
+// after the start.
+func (r Result) Until() (Clock, bool) {
+	if r.HasEnd {
+		return r.End, true
+	}
+	if r.HasStart && r.Duration > 0 {
+		return r.Start.Add(r.Duration), true
+	}
+	return Clock{}, false
+}
+
+// token is a word of the input with its byte span in the original text.
+type token struct {
+	text       string // lower-cased, ё → е
+	start, end int
+}
+
+// tokenize splits on spaces and makes separate tokens of commas and dashes
+// (but keeps "9:30", "9.30" and "1,5" whole).
+func tokenize(s string) []token {
+	var out []token
+	rs := []rune(s)
+	offs := make([]int, len(rs)+1)
+	for i, o := 0, 0; i < len(rs); i++ {
+		offs[i] = o
+		o += len(string(rs[i]))
+		offs[i+1] = o
+	}
+	isDigit := func(i int) bool { return i >= 0 && i < len(rs) && unicode.IsDigit(rs[i]) }
+	begin := -1
+	flush := func(i int) {
+		if begin >= 0 {
+			t := strings.ToLower(string(rs[begin:i]))
+			t = strings.ReplaceAll(t, "ё", "е")
+			t = strings.TrimRight(t, ".!?;")
+			if t != "" {
+				out = append(out, token{text: t, start: offs[begin], end: offs[i]})
+			}
+			begin = -1
+		}
+	}
+	for i, r := range rs {
+		switch {
+		case unicode.IsSpace(r):
+			flush(i)
+		case r == ',' && !(isDigit(i-1) && isDigit(i+1)):
+			flush(i)
+			out = append(out, token{text: ",", start: offs[i], end: offs[i+1]})
+		case r == '-' || r == '–' || r == '—':
+			flush(i)
+			out = append(out, token{text: "-", start: offs[i], end: offs[i+1]})
+		default:
+			if begin < 0 {
+				begin = i
+			}
+		}
+	}
+	flush(len(rs))
+	return out
+}
+
+// parser walks the tokens; used marks tokens that were recognised.
+type parser struct {
+	toks []token
+	used []bool
+}
+
+func (p *parser) at(i int) string {
+	if i < 0 || i >= len(p.toks) {
+		return ""
+	}
+	return p.toks[i].text
+}
+
+func (p *parser) mark(from, to int) {
+	for i := from; i < to; i++ {
+		p.used[i] = true
+	}
+}
+
+func oneOf(s string, words ...string) bool {
+	for _, w := range words {
+		if s == w {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse finds a start, end, duration, days and a relative day in s.
+// Unrecognised words are kept in Rest.
+func Parse(s string) Result {
+	p := &parser{toks: tokenize(s)}
+	p.used = make([]bool, len(p.toks))
+	var r Result
+	for i := 0; i < len(p.toks); {
+		if n := p.step(i, &r); n > i {
+			i = n
+			continue
+		}
+		i++
+	}
+	r.Rest = p.rest(s)
+	return r
+}
+
+// step tries every rule at i and returns the index after what it consumed,
+// or i if nothing matched.
+func (p *parser) step(i int, r *Result) int {
+	w := p.at(i)
+	// "с 9 до 10", "from 9 to 5pm"
+	if oneOf(w, "с", "со", "from") {
+		if c, ex, n, ok := p.clock(i+1, true); ok && !r.HasStart {
+			end := p.rangeEnd(n, c, ex, r)
+			r.Start, r.HasStart = c, true
+			p.mark(i, end)
+			return end
+		}
+	}
+	// "9-10:30", "9am to 5pm"
+	if c, ex, n, ok := p.clock(i, false); ok && !r.HasStart {
+		if end := p.rangeEnd(n, c, ex, r); end > n || ex || !plainNumber(w) {
+			r.Start, r.HasStart = c, true
+			p.mark(i, end)
+			return end
+		}
+	}
+	// "в 14", "at 9", "к 10"
+	if oneOf(w, "в", "во", "at", "к") && !r.HasStart {
+		if c, ex, n, ok := p.clock(i+1, true); ok {
+			end := p.rangeEnd(n, c, ex, r)
+			r.Start, r.HasStart = c, true
+			p.mark(i, end)
+			return end
+		}
+	}
+	// "до 18", "until 5pm"
+	if oneOf(w, "до", "till", "until") && r.HasStart && !r.HasEnd {
+		if c, ex, n, ok := p.clock(i+1, true); ok {
+			r.End, r.HasEnd = fixEnd(r.Start, c, ex), true
+			p.mark(i, n)
+			return n
+		}
+	}
+	// "на 45 минут", "for an hour"
+	if oneOf(w, "на", "for") {
+		if d, n, ok := p.duration(i + 1); ok {
+			r.Duration = d
+			p.mark(i, n)
+			return n
+		}
+	}
+	// "1h30m" needs no preposition
+	if d, ok := compact(w); ok {
+		r.Duration = d
+		p.mark(i, i+1)
+		return i + 1
+	}
+	// "сегодня", "завтра", "tomorrow"
+	if off, n, ok := p.relativeDay(i); ok {
+		r.DayOffset, r.HasDay = off, true
+		p.mark(i, n)
+		return n
+	}
+	// "каждый вторник и четверг", "по будням", "every weekday", "пн-пт"
+	if mask, n, ok := p.days(i); ok {
+		r.DaysMask |= mask
+		p.mark(i, n)
+		return n
+	}
+	return i
+}
+
+// rangeEnd parses an optional "- 10", "до 10", "to 5pm" after a start at n
+// and records the end; it returns the index after the range.
+func (p *parser) rangeEnd(n int, start Clock, startExplicit bool, r *Result) int {
+	if !oneOf(p.at(n), "-", "до", "по", "to", "till", "until") {
+		return n
+	}
+	c, ex, m, ok := p.clock(n+1, true)
+	if !ok {
+		return n
+	}
+	// "9-5pm": a start without am/pm follows the end's half of the day
+	if ex && !startExplicit && c.H >= 12 && start.H < 12 && start.H+12 < c.H+1 {
+		start.H += 12
+		r.Start = start
+	}
+	r.End, r.HasEnd = fixEnd(start, c, ex), true
+	return m
+}
+
+// fixEnd reads a bare end hour as p.m. when that makes "с 9 до 5" a
+// plausible 9:00–17:00 rather than an overnight span.
+func fixEnd(start, end Clock, explicit bool) Clock {
+	if !explicit && end.H < 12 && end.H*60+end.M <= start.H*60+start.M && (end.H+12)*60+end.M > start.H*60+start.M {
+		end.H += 12
+	}
+	return end
+}
+
+// rest is the input with the recognised tokens cut out.
+func (p *parser) rest(s string) string {
+	var b strings.Builder
+	last := 0
+	for i, t := range p.toks {
+		if p.used[i] {
+			b.WriteString(s[last:t.start])
+			b.WriteString(" ")
+			last = t.end
+		}
+	}
+	b.WriteString(s[last:])
+	out := strings.Join(strings.Fields(b.String()), " ")
+	return strings.Trim(out, " ,-–—")
+}
+
+// ParseClock reads a phrase that is only a time: "9", "9:30", "в 9 утра",
+// "полдесятого", "half past ten", "5pm".
+func ParseClock(s string) (Clock, bool) {
+	p := &parser{toks: tokenize(s)}
+	i := 0
+	if oneOf(p.at(0), "в", "во", "at", "к", "с", "со", "from", "до", "till", "until") {
+		i = 1
+	}
+	c, _, n, ok := p.clock(i, true)
+	return c, ok && n == len(p.toks)
+}
+
+// ParseDuration reads a phrase that is only a duration: "45 минут",
+// "на полтора часа", "1h30m", "for an hour".
+func ParseDuration(s string) (time.Duration, bool) {
+	p := &parser{toks: tokenize(s)}
+	i := 0
+	if oneOf(p.at(0), "на", "for") {
+		i = 1
+	}
+	d, n, ok := p.duration(i)
+	return d, ok && n == len(p.toks)
+}
+
+// ParseDays reads a phrase that is only a set of weekdays: "пн-пт",
+// "каждый вторник и четверг", "по выходным", "every weekday".
+func ParseDays(s string) (int, bool) {
+	p := &parser{toks: tokenize(s)}
+	mask, n, ok := p.days(0)
+	return mask, ok && n == len(p.toks)
+}
+
+// number reads digits ("45", "1,5", "1.5") or number words ("сорок пять").
+func (p *parser) number(i int) (float64, int, bool) {
+	w := strings.Replace(p.at(i), ",", ".", 1)
+	if v, err := strconv.ParseFloat(w, 64); err == nil && w != "" && !strings.HasPrefix(w, "-") {
+		return v, i + 1, true
+	}
+	tens, ok := tensWords[p.at(i)]
+	if ok {
+		if u, ok := unitWords[p.at(i+1)]; ok && u < 10 {
+			return float64(tens + u), i + 2, true
+		}
+		return float64(tens), i + 1, true
+	}
+	if u, ok := unitWords[p.at(i)]; ok {
+		return float64(u), i + 1, true
+	}
+	return 0, i, false
+}
+
+var unitWords = map[string]int{
+	"один": 1, "одна": 1, "одну": 1, "два": 2, "две": 2, "три": 3, "четыре": 4, "пять": 5, "шесть": 6,
+	"семь": 7, "восемь": 8, "девять": 9, "десять": 10, "одиннадцать": 11, "двенадцать": 12,
+	"пятнадцать": 15, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7,
+	"eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12, "fifteen": 15,
+}
+
+var tensWords = map[string]int{
+	"двадцать": 20, "тридцать": 30, "сорок": 40, "пятьдесят": 50,
+	"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50,
+}
+
+// ordinals are genitive hour ordinals: "половина десятого" is 9:30.
+var ordinals = map[string]int{
+	"первого": 1, "второго": 2, "третьего": 3, "четвертого": 4, "пятого": 5, "шестого": 6,
+	"седьмого": 7, "восьмого": 8, "девятого": 9, "десятого": 10, "одиннадцатого": 11, "двенадцатого": 12,
+}
+
+// minutesGen are genitive minute counts after "без": "без пяти десять".
+var minutesGen = map[string]int{
+	"пяти": 5, "десяти": 10, "пятнадцати": 15, "четверти": 15, "двадцати": 20, "двадцати пяти": 25,
+}
+
+// clock reads a time of day at i. bare allows a lone hour ("9",
+// "девять"), which is otherwise too ambiguous. explicit is true when the
+// half of the day was given (am/pm, утра/вечера) or is implied (14:00).
+func (p *parser) clock(i int, bare bool) (c Clock, explicit bool, next int, ok bool) {
+	w := p.at(i)
+	switch {
+	case oneOf(w, "полдень", "noon", "midday"):
+		return Clock{12, 0}, true, i + 1, true
+	case oneOf(w, "полночь", "midnight"):
+		return Clock{0, 0}, true, i + 1, true
+	case oneOf(w, "половина", "половины", "пол") && ordinals[p.at(i+1)] > 0:
+		return p.marker(Clock{ordinals[p.at(i+1)] - 1, 30}, i+2)
+	case strings.HasPrefix(w, "пол") && ordinals[strings.TrimPrefix(strings.TrimPrefix(w, "пол"), "-")] > 0:
+		return p.marker(Clock{ordinals[strings.TrimPrefix(strings.TrimPrefix(w, "пол"), "-")] - 1, 30}, i+1)
+	case w == "четверть" && ordinals[p.at(i+1)] > 0:
+		return p.marker(Clock{ordinals[p.at(i+1)] - 1, 15}, i+2)
+	case w == "без":
+		m, n := 0, i+1
+		if v, ok := minutesGen[p.at(i+1)+" "+p.at(i+2)]; ok {
+			m, n = v, i+3
+		} else if v, ok := minutesGen[p.at(i+1)]; ok {
+			m, n = v, i+2
+		} else if v, err := strconv.Atoi(p.at(i + 1)); err == nil && v > 0 && v < 60 {
+			m, n = v, i+2
+		} else {
+			return Clock{}, false, i, false
+		}
+		h, n2, ok := p.hour(n)
+		if !ok || h < 1 || h > 12 {
+			return Clock{}, false, i, false
+		}
+		return p.marker(Clock{h - 1, 60 - m}, n2)
+	case oneOf(w, "half", "quarter") && p.at(i+1) == "past":
+		h, n, ok := p.hour(i + 2)
+		if !ok {
+			return Clock{}, false, i, false
+		}
+		m := 30
+		if w == "quarter" {
+			m = 15
+		}
+		return p.marker(Clock{h, m}, n)
+	case w == "quarter" && p.at(i+1) == "to":
+		h, n, ok := p.hour(i + 2)
+		if !ok || h < 1 {
+			return Clock{}, false, i, false
+		}
+		return p.marker(Clock{h - 1, 45}, n)
+	}
+	if c, ex, ok := numericClock(w); ok {
+		if ex && !plainNumber(w) {
+			return c, true, i + 1, true
+		}
+		cm, exm, n, _ := p.marker(c, i+1)
+		if !bare && plainNumber(w) && n == i+1 {
+			// a lone number counts only in a range or with a marker
+			return c, false, i + 1, oneOf(p.at(i+1), "-", "до", "to")
+		}
+		return cm, exm, n, true
+	}
+	if bare {
+		if h, n, ok := p.hour(i); ok {
+			return p.marker(Clock{h, 0}, n)
+		}
+	}
+	return Clock{}, false, i, false
+}
+
+// plainNumber reports whether w is digits only: "20" in "20 страниц" is
+// not a time unless something around it says so.
+func plainNumber(w string) bool {
+	_, err := strconv.Atoi(w)
+	return err == nil
+}
+
+// hour reads an hour as digits or a word ("девять", "nine", "час").
+func (p *parser) hour(i int) (int, int, bool) {
+	w := p.at(i)
+	if v, err := strconv.Atoi(w); err == nil && v >= 0 && v <= 24 {
+		return v % 24, i + 1, true
+	}
+	if w == "час" {
+		return 1, i + 1, true
+	}
+	if v, ok := unitWords[w]; ok && v <= 12 {
+		return v, i + 1, true
+	}
+	return 0, i, false
+}
+
+// numericClock reads "9", "09:30", "9.30", "9am", "9:30pm".
+func numericClock(w string) (Clock, bool, bool) {
+	suffix := ""
+	for _, s := range []string{"a.m", "p.m", "am", "pm"} {
+		if strings.HasSuffix(w, s) {
+			suffix, w = s, strings.TrimSuffix(w, s)
+			break
+		}
+	}
+	hs, ms, hasMin := strings.Cut(w, ":")
+	if !hasMin {
+		hs, ms, hasMin = strings.Cut(w, ".")
+	}
+	h, err := strconv.Atoi(hs)
+	if err != nil || h < 0 || h > 24 || hs == "" {
+		return Clock{}, false, false
+	}
+	m := 0
+	if hasMin {
+		if len(ms) != 2 {
+			return Clock{}, false, false
+		}
+		if m, err = strconv.Atoi(ms); err != nil || m > 59 {
+			return Clock{}, false, false
+		}
+	}
+	c := Clock{h % 24, m}
+	switch {
+	case suffix != "":
+		if h < 1 || h > 12 {
+			return Clock{}, false, false
+		}
+		return half(c, strings.HasPrefix(suffix, "p")), true, true
+	case h > 12 || (hasMin && len(hs) == 2 && hs[0] == '0'):
+		return c, true, true
+	}
+	return c, false, true
+}
+
+// half converts a 12-hour clock to 24 hours.
+func half(c Clock, pm bool) Clock {
+	c.H %= 12
+	if pm {
+		c.H += 12
+	}
+	return c
+}
+
+// marker applies an optional "утра/дня/вечера/ночи", "am/pm" or "часов"
+// after a time.
+func (p *parser) marker(c Clock, i int) (Clock, bool, int, bool) {
+	if oneOf(p.at(i), "час", "часа", "часов", "o'clock", "ч") {
+		i++
+	}
+	switch w := p.at(i); {
+	case oneOf(w, "утра", "am", "a.m", "morning"):
+		return half(c, false), true, i + 1, true
+	case oneOf(w, "дня", "вечера", "pm", "p.m", "evening"):
+		if c.H >= 12 {
+			return c, true, i + 1, true
+		}
+		return half(c, true), true, i + 1, true
+	case w == "ночи":
+		if c.H == 12 {
+			c.H = 0
+		}
+		return c, true, i + 1, true
+	}
+	return c, c.H > 12, i, true
+}
+
+// duration reads "45 минут", "полтора часа", "1.5 hours", "an hour",
+// "1ч30м", "45m".
+func (p *parser) duration(i int) (time.Duration, int, bool) {
+	w := p.at(i)
+	switch {
+	case w == "полчаса":
+		return 30 * time.Minute, i + 1, true
+	case w == "полтора" && strings.HasPrefix(p.at(i+1), "час"):
+		return 90 * time.Minute, i + 2, true
+	case oneOf(w, "час", "hour"):
+		return time.Hour, i + 1, true
+	case oneOf(w, "an", "a") && p.at(i+1) == "hour":
+		return time.Hour, i + 2, true
+	case w == "half" && p.at(i+1) == "an" && p.at(i+2) == "hour":
+		return 30 * time.Minute, i + 3, true
+	}
+	if d, ok := compact(w); ok {
+		return d, i + 1, true
+	}
+	v, n, ok := p.number(i)
+	if !ok || v <= 0 {
+		return 0, i, false
+	}
+	unit := p.at(n)
+	switch {
+	case strings.HasPrefix(unit, "мин") || oneOf(unit, "м", "m", "min", "mins", "minute", "minutes"):
+		return time.Duration(v * float64(time.Minute)), n + 1, true
+	case strings.HasPrefix(unit, "час") || oneOf(unit, "ч", "h", "hr", "hrs", "hour", "hours"):
+		return time.Duration(v * float64(time.Hour)), n + 1, true
+	}
+	return 0, i, false
+}
+
+// compact reads "1h30m", "1ч30м", "45m", "2h".
+func compact(w string) (time.Duration, bool) {
+	var d time.Duration
+	num := ""
+	seen := false
+	for _, r := range w {
+		switch {
+		case unicode.IsDigit(r):
+			num += string(r)
+		case (r == 'h' || r == 'ч') && num != "":
+			v, _ := strconv.Atoi(num)
+			d += time.Duration(v) * time.Hour
+			num, seen = "", true
+		case (r == 'm' || r == 'м') && num != "":
+			v, _ := strconv.Atoi(num)
+			d += time.Duration(v) * time.Minute
+			num, seen = "", true
+		default:
+			return 0, false
+		}
+	}
+	return d, seen && num == "" && d > 0
+}
+
+// relativeDay reads "сегодня", "завтра", "послезавтра", "today",
+// "tomorrow", "day after tomorrow".
+func (p *parser) relativeDay(i int) (int, int, bool) {
+	switch w := p.at(i); {
+	case oneOf(w, "сегодня", "today", "tonight"):
+		return 0, i + 1, true
+	case oneOf(w, "завтра", "tomorrow"):
+		return 1, i + 1, true
+	case w == "послезавтра":
+		return 2, i + 1, true
+	case w == "day" && p.at(i+1) == "after" && p.at(i+2) == "tomorrow":
+		return 2, i + 3, true
+	}
+	return 0, i, false
+}
+
+// dayForms lists every case form, singular and plural, of the weekday
+// names; a prefix would also match "средство" or "среди".
+var dayForms = []struct {
+	bit   int
+	words string
+}{
+	{timeutil.BitMon, "понедельник понедельника понедельнику понедельником понедельнике понедельники понедельников понедельникам понедельниками понедельниках monday mondays mon"},
+	{timeutil.BitTue, "вторник вторника вторнику вторником вторнике вторники вторников вторникам вторниками вторниках tuesday tuesdays tue"},
+	{timeutil.BitWed, "среда среды среде среду средой сред средам средами средах wednesday wednesdays wed"},
+	{timeutil.BitThu, "четверг четверга четвергу четвергом четверге четверги четвергов четвергам четвергами четвергах thursday thursdays thu"},
+	{timeutil.BitFri, "пятница пятницы пятнице пятницу пятницей пятниц пятницам пятницами пятницах friday fridays fri"},
+	{timeutil.BitSat, "суббота субботы субботе субботу субботой суббот субботам субботами субботах saturday saturdays sat"},
+	{timeutil.BitSun, "воскресенье воскресенья воскресенью воскресеньем воскресений воскресеньям воскресеньями воскресеньях sunday sundays sun"},
+}
+
+// dayWords maps the weekday names and abbreviations to their bit.
+var dayWords = func() map[string]int {
+	m := map[string]int{
+		"пн": timeutil.BitMon, "вт": timeutil.BitTue, "ср": timeutil.BitWed, "чт": timeutil.BitThu,
+		"пт": timeutil.BitFri, "сб": timeutil.BitSat, "вс": timeutil.BitSun,
+	}
+	for _, d := range dayForms {
+		for _, w := range strings.Fields(d.words) {
+			m[w] = d.bit
+		}
+	}
+	return m
+}()
+
+// dayCodes are the two-letter English codes; "we", "sa" and "su" are
+// ordinary words too, so they count only after "on"/"every" or in a list
+// of codes ("mo-fr", "tu and th").
+var dayCodes = map[string]int{
+	"mo": timeutil.BitMon, "tu": timeutil.BitTue, "we": timeutil.BitWed, "th": timeutil.BitThu,
+	"fr": timeutil.BitFri, "sa": timeutil.BitSat, "su": timeutil.BitSun,
+}
+
+// weekday reads one weekday name or abbreviation, and a two-letter code
+// if codes is set.
+func weekday(w string, codes bool) (int, bool) {
+	if b, ok := dayWords[w]; ok {
+		return b, true
+	}
+	if b, ok := dayCodes[w]; ok && codes {
+		return b, true
+	}
+	return 0, false
+}
+
+// dayGroup reads a word for several days: "будни", "выходные", "weekdays".
+func (p *parser) dayGroup(i int) (int, int, bool) {
+	switch w := p.at(i); {
+	case oneOf(w, "будни", "будням", "weekday", "weekdays", "workdays", "будний"):
+		if w == "будний" && strings.HasPrefix(p.at(i+1), "ден") {
+			return timeutil.MaskWorkdays(), i + 2, true
+		}
+		return timeutil.MaskWorkdays(), i + 1, true
+	case oneOf(w, "рабочие", "рабочим", "рабочий") && strings.HasPrefix(p.at(i+1), "д"):
+		return timeutil.MaskWorkdays(), i + 2, true
+	case oneOf(w, "выходные", "выходным", "weekend", "weekends", "выходной"):
+		return timeutil.BitSat | timeutil.BitSun, i + 1, true
+	case oneOf(w, "ежедневно", "daily", "everyday"):
+		return timeutil.MaskDaily(), i + 1, true
+	}
+	return 0, i, false
+}
+
+// days reads a set of weekdays with an optional leading "каждый", "по",
+// "every", "в", "on": "вторник и четверг", "пн-пт", "mon, wed and fri",
+// "каждый день".
+func (p *parser) days(i int) (int, int, bool) {
+	start := i
+	lead := ""
+	if oneOf(p.at(i), "каждый", "каждую", "каждое", "каждые", "по", "every", "each", "в", "во", "on") {
+		lead = p.at(i)
+		i++
+	}
+	if lead != "" && oneOf(p.at(i), "день", "дни", "day") {
+		return timeutil.MaskDaily(), i + 1, true
+	}
+	if mask, n, ok := p.dayGroup(i); ok {
+		return mask, n, true
+	}
+	mask, n, items, codes := p.dayList(i, true)
+	if codes > 0 && !oneOf(lead, "on", "every", "each") && (codes < items || items < 2) {
+		mask, n, _, _ = p.dayList(i, false)
+	}
+	if mask == 0 {
+		return 0, start, false
+	}
+	return mask, n, true
+}
+
+// dayList reads weekdays joined by ",", "и", "and", "&" or "-" from i; it
+// returns the index after them, how many days were named and how many of
+// those were two-letter codes.
+func (p *parser) dayList(i int, codes bool) (mask, n, items, short int) {
+	n = i
+	item := func(w string) (int, bool) {
+		bit, ok := weekday(w, codes)
+		if ok {
+			items++
+			if _, code := dayCodes[w]; code {
+				short++
+			}
+		}
+		return bit, ok
+	}
+	for {
+		bit, ok := item(p.at(n))
+		if !ok {
+			break
+		}
+		if p.at(n+1) == "-" {
+			if to, ok := item(p.at(n + 2)); ok {
+				mask |= dayRange(bit, to)
+				n += 3
+			} else {
+				mask |= bit
+				n++
+			}
+		} else {
+			mask |= bit
+			n++
+		}
+		// separators: ",", "и", "and", "&", or another "по"/"в"
+		if oneOf(p.at(n), ",", "и", "and", "&") {
+			if _, ok := weekday(p.at(n+1), codes); ok {
+				n++
+				continue
+			}
+			if p.at(n) == "," && p.at(n+1) == "and" {
+				if _, ok := weekday(p.at(n+2), codes); ok {
+					n += 2
+					continue
+				}
+			}
+		}
+		if _, ok := weekday(p.at(n), codes); !ok {
+			break
+		}
+	}
+	return mask, n, items, short
+}
+
+// dayRange is every day from a to b inclusive, wrapping past Sunday.
+func dayRange(a, b int) int {
+	mask := 0
+	for bit := a; ; {
+		mask |= bit
+		if bit == b {
+			return mask
+		}
+		bit <<= 1
+		if bit > timeutil.BitSun {
+			bit = timeutil.BitMon
+		}
+	}
+}
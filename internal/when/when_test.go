@@ -0,0 +1,124 @@
+package when
+
+import (
+	"testing"
+	"time"
+
+	"github.com/okpulse/telegram-schedule-bot/internal/timeutil"
+)
+
+func TestParse(t *testing.T) {
+	workdays := timeutil.MaskWorkdays()
+	weekend := timeutil.BitSat | timeutil.BitSun
+	tests := []struct {
+		in   string
+		want Result
+	}{
+		// phrases from the package doc
+		{"с 9 до половины одиннадцатого", Result{Start: Clock{9, 0}, HasStart: true, End: Clock{10, 30}, HasEnd: true}},
+		{"завтра в 14", Result{Start: Clock{14, 0}, HasStart: true, DayOffset: 1, HasDay: true}},
+		{"каждый вторник и четверг", Result{DaysMask: timeutil.BitTue | timeutil.BitThu}},
+		{"every weekday 9am-5pm", Result{Start: Clock{9, 0}, HasStart: true, End: Clock{17, 0}, HasEnd: true, DaysMask: workdays}},
+		{"на 45 минут", Result{Duration: 45 * time.Minute}},
+
+		{"без четверти 6", Result{Start: Clock{5, 45}, HasStart: true}},
+		{"с 22 до 2", Result{Start: Clock{22, 0}, HasStart: true, End: Clock{2, 0}, HasEnd: true}},
+		{"послезавтра", Result{DayOffset: 2, HasDay: true}},
+		{"at 7pm for 30 minutes", Result{Start: Clock{19, 0}, HasStart: true, Duration: 30 * time.Minute}},
+		{"every monday and friday", Result{DaysMask: timeutil.BitMon | timeutil.BitFri}},
+
+		// the title is what is left
+		{"Стендап с 9 до 9:15 по будням", Result{Start: Clock{9, 0}, HasStart: true, End: Clock{9, 15}, HasEnd: true, DaysMask: workdays, Rest: "Стендап"}},
+		{"Обед в 13 на час", Result{Start: Clock{13, 0}, HasStart: true, Duration: time.Hour, Rest: "Обед"}},
+		{"Позвонить маме завтра в 18:30", Result{Start: Clock{18, 30}, HasStart: true, DayOffset: 1, HasDay: true, Rest: "Позвонить маме"}},
+		{"по выходным 10-12 Зал", Result{Start: Clock{10, 0}, HasStart: true, End: Clock{12, 0}, HasEnd: true, DaysMask: weekend, Rest: "Зал"}},
+
+		// numbers that are not times stay in the title
+		{"Прочитать 2 главы", Result{Rest: "Прочитать 2 главы"}},
+		{"Купить 3 яблока", Result{Rest: "Купить 3 яблока"}},
+		{"в 25", Result{Rest: "в 25"}},
+		{"в 23:60", Result{Rest: "в 23:60"}},
+
+		// weekdays are whole words; two-letter codes need "on"/"every" or a list
+		{"Купить средство в 10", Result{Start: Clock{10, 0}, HasStart: true, Rest: "Купить средство"}},
+		{"Созвон среди недели", Result{Rest: "Созвон среди недели"}},
+		{"Средний чек", Result{Rest: "Средний чек"}},
+		{"Хор по средам в 19", Result{Start: Clock{19, 0}, HasStart: true, DaysMask: timeutil.BitWed, Rest: "Хор"}},
+		{"Уборка в субботу", Result{DaysMask: timeutil.BitSat, Rest: "Уборка"}},
+		{"we meet at 9", Result{Start: Clock{9, 0}, HasStart: true, Rest: "we meet"}},
+		{"sa su", Result{DaysMask: weekend}},
+		{"Gym on sa", Result{DaysMask: timeutil.BitSat, Rest: "Gym"}},
+		{"Standup mo-fr", Result{DaysMask: workdays, Rest: "Standup"}},
+		{"monday and we talk", Result{DaysMask: timeutil.BitMon, Rest: "and we talk"}},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.in); got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestResultUntil(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   Clock
+		wantOK bool
+	}{
+		{"с 9 до 10", Clock{10, 0}, true},
+		{"в 23:30 на час", Clock{0, 30}, true},
+		{"в 9", Clock{}, false},
+		{"на 45 минут", Clock{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.in).Until()
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Parse(%q).Until() = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   Clock
+		wantOK bool
+	}{
+		{"9:30", Clock{9, 30}, true},
+		{"в 14", Clock{14, 0}, true},
+		{"половина одиннадцатого", Clock{10, 30}, true},
+		{"5pm", Clock{17, 0}, true},
+		{"24:00", Clock{0, 0}, true},
+		{"25:00", Clock{}, false},
+		{"в 23:60", Clock{}, false},
+		{"Прочитать 2 главы", Clock{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseClock(tt.in)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("ParseClock(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   int
+		wantOK bool
+	}{
+		{"пн-пт", timeutil.MaskWorkdays(), true},
+		{"по средам и пятницам", timeutil.BitWed | timeutil.BitFri, true},
+		{"tu and th", timeutil.BitTue | timeutil.BitThu, true},
+		{"every su", timeutil.BitSun, true},
+		{"средство", 0, false},
+		{"среди", 0, false},
+		{"we", 0, false},
+		{"su", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseDays(tt.in)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("ParseDays(%q) = %b, %v, want %b, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}